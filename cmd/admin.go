@@ -0,0 +1,441 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/spf13/cobra"
+)
+
+var errNotAdmin = errors.New("Permission denied: only admins may administer the server.")
+
+// AdminSecretOutput is the machine-readable representation of a secret
+// listed to an admin, along with whoever owns it.
+type AdminSecretOutput struct {
+	SecretOutput `yaml:",inline"`
+	Owner        string `json:"owner" yaml:"owner"`
+}
+
+// newAdminCmd builds the commands reserved to admins, the users whose keys
+// are listed in SSSS_ADMIN_KEYS.
+func newAdminCmd(sess ssh.Session) *cobra.Command {
+	adminCmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Administers the server, for admins only.",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if !isAdmin(sessionUser(sess).PublicKey) {
+				return errNotAdmin
+			}
+
+			return nil
+		},
+	}
+
+	registrationsCmd := &cobra.Command{
+		Use:   "registrations",
+		Short: "Lists the registrations waiting for approval.",
+		Args:  cobra.NoArgs,
+		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			out := cmd.OutOrStdout()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			users, err := usersWithStatus(repo, userPending)
+			if err != nil {
+				return err
+			}
+			recordAdminEvent(repo, sessionUser(sess).ID, model.EventRegistrationsListed, "", "")
+
+			outputs := make([]UserOutput, 0, len(users))
+			for _, user := range users {
+				o, err := newUserOutput(repo, user)
+				if err != nil {
+					return err
+				}
+				outputs = append(outputs, o)
+			}
+
+			if format != "table" {
+				return writeOutput(out, format, outputs)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "USERNAME\tFINGERPRINT\tREQUESTED")
+			for _, o := range outputs {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", o.Username, strings.Join(o.Keys, ","), seen(o.FirstSeen))
+			}
+
+			return tw.Flush()
+		}),
+	}
+
+	usersCmd := &cobra.Command{
+		Use:   "users",
+		Short: "Lists every user, flagging shareholders who haven't connected lately.",
+		Args:  cobra.NoArgs,
+		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			out := cmd.OutOrStdout()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			users, err := repo.User().List()
+			if err != nil {
+				return err
+			}
+			sort.Slice(users, func(i, j int) bool {
+				return users[i].Username < users[j].Username
+			})
+			recordAdminEvent(repo, sessionUser(sess).ID, model.EventUsersListed, "", "")
+
+			inactive, _ := cmd.Flags().GetBool("inactive")
+			outputs := make([]UserOutput, 0, len(users))
+			for _, user := range users {
+				o, err := newUserOutput(repo, user)
+				if err != nil {
+					return err
+				}
+				if inactive && !o.Inactive {
+					continue
+				}
+				outputs = append(outputs, o)
+			}
+
+			if format != "table" {
+				return writeOutput(out, format, outputs)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "USERNAME\tSTATUS\tKEYS\tSHARES\tFIRST SEEN\tLAST SEEN\tINACTIVE")
+			for _, o := range outputs {
+				inactive := ""
+				if o.Inactive {
+					inactive = "yes"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%s\n", o.Username, o.Status, len(o.Keys), o.Shares, seen(o.FirstSeen), seen(o.LastSeen), inactive)
+			}
+
+			return tw.Flush()
+		}),
+	}
+
+	approveCmd := &cobra.Command{
+		Use:   "approve {username|fingerprint}",
+		Short: "Approves a pending registration.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			return setRegistration(cmd, repo, sessionUser(sess), args[0], userActive)
+		}),
+	}
+
+	rejectCmd := &cobra.Command{
+		Use:   "reject {username|fingerprint}",
+		Short: "Rejects a pending registration.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			return setRegistration(cmd, repo, sessionUser(sess), args[0], userRejected)
+		}),
+	}
+
+	disableCmd := &cobra.Command{
+		Use:   "disable {username|fingerprint}",
+		Short: "Disables a user, who may no longer connect.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			return setDisabled(cmd, repo, sessionUser(sess), args[0], true)
+		}),
+	}
+
+	enableCmd := &cobra.Command{
+		Use:   "enable {username|fingerprint}",
+		Short: "Enables a disabled user again.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			return setDisabled(cmd, repo, sessionUser(sess), args[0], false)
+		}),
+	}
+
+	secretsCmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Lists every secret, whoever owns it.",
+		Args:  cobra.NoArgs,
+		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			out := cmd.OutOrStdout()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			secrets, err := repo.Secret().All()
+			if err != nil {
+				return err
+			}
+			sortSecrets(secrets)
+			recordAdminEvent(repo, sessionUser(sess).ID, model.EventSecretsListed, "", "")
+
+			outputs := make([]AdminSecretOutput, 0, len(secrets))
+			for _, secret := range secrets {
+				o, err := newSecretOutput(repo, &secret)
+				if err != nil {
+					return err
+				}
+
+				owner, err := repo.User().Get(secret.User)
+				if err != nil {
+					return err
+				}
+
+				outputs = append(outputs, AdminSecretOutput{SecretOutput: o, Owner: owner.Username})
+			}
+
+			if format != "table" {
+				return writeOutput(out, format, outputs)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tLABEL\tOWNER\tPARTS\tTHRESHOLD\tSTATUS\tENCRYPTION\tCREATED")
+			for _, o := range outputs {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n", o.ID, o.Label, o.Owner, o.Parts, o.Threshold, o.Status, o.Encryption, o.CreatedAt.Format(timeFormat))
+			}
+
+			return tw.Flush()
+		}),
+	}
+
+	ceremoniesCmd := &cobra.Command{
+		Use:   "ceremonies",
+		Short: "Lists every running ceremony.",
+		Args:  cobra.NoArgs,
+		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			out := cmd.OutOrStdout()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			outputs, err := ceremonies(repo)
+			if err != nil {
+				return err
+			}
+			recordAdminEvent(repo, sessionUser(sess).ID, model.EventCeremoniesListed, "", "")
+
+			if format != "table" {
+				return writeOutput(out, format, outputs)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tLABEL\tCEREMONY\tINITIATOR\tSIGNED\tUNSIGNED\tEXPIRES")
+			for _, o := range outputs {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", o.ID, o.Label, o.Ceremony, o.Initiator, o.Signed, o.Unsigned, o.ExpiresAt.Format(timeFormat))
+			}
+
+			return tw.Flush()
+		}),
+	}
+
+	killCmd := &cobra.Command{
+		Use:   "kill {id}",
+		Short: "Kills the ceremony running for a secret.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			secret, err := repo.Secret().Get(args[0])
+			if err != nil {
+				return err
+			}
+
+			kind, err := killCeremony(repo, secret)
+			if err != nil {
+				return err
+			}
+			recordAdminEvent(repo, sessionUser(sess).ID, model.EventCeremonyKilled, secret.ID, "")
+
+			log.Info("Killed a ceremony", "id", secret.ID, "ceremony", kind, "admin", sessionUser(sess).ID)
+			fmt.Fprintf(cmd.ErrOrStderr(), "Killed the %s ceremony of %s.\n", kind, shortID(secret.ID))
+			return nil
+		}),
+	}
+
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Lists the actions taken by admins.",
+		Args:  cobra.NoArgs,
+		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			out := cmd.OutOrStdout()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			events, err := repo.Audit().All()
+			if err != nil {
+				return err
+			}
+
+			outputs, err := newAdminEventOutputs(repo, events)
+			if err != nil {
+				return err
+			}
+			recordAdminEvent(repo, sessionUser(sess).ID, model.EventAdminAuditListed, "", "")
+
+			if format != "table" {
+				return writeOutput(out, format, outputs)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "TIME\tACTION\tADMIN\tSECRET\tSUBJECT")
+			for _, o := range outputs {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", o.CreatedAt.Format(timeFormat), o.Action, o.Admin, o.Secret, o.Subject)
+			}
+
+			return tw.Flush()
+		}),
+	}
+
+	usersCmd.Flags().Bool("inactive", false, "Only list the shareholders who haven't connected for SSSS_INACTIVE_AFTER.")
+
+	usersCmd.AddCommand(disableCmd)
+	usersCmd.AddCommand(enableCmd)
+
+	adminCmd.AddCommand(secretsCmd)
+	adminCmd.AddCommand(ceremoniesCmd)
+	adminCmd.AddCommand(killCmd)
+	adminCmd.AddCommand(usersCmd)
+	adminCmd.AddCommand(registrationsCmd)
+	adminCmd.AddCommand(approveCmd)
+	adminCmd.AddCommand(rejectCmd)
+	adminCmd.AddCommand(auditCmd)
+
+	return adminCmd
+}
+
+// usersWithStatus lists the users with the status, by username.
+func usersWithStatus(repo repository.Repository, status string) ([]model.User, error) {
+	users, err := repo.User().List()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []model.User{}
+	for _, user := range users {
+		if user.Status == status {
+			matching = append(matching, user)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].Username < matching[j].Username
+	})
+
+	return matching, nil
+}
+
+// matchUsers lists the users with the username, or with a key of the
+// fingerprint.
+func matchUsers(users []model.User, identifier string) []model.User {
+	matching := []model.User{}
+	for _, user := range users {
+		if user.Username == identifier {
+			matching = append(matching, user)
+			continue
+		}
+
+		for _, key := range userKeys(user) {
+			if displayShareholder(key) == identifier {
+				matching = append(matching, user)
+				break
+			}
+		}
+	}
+
+	return matching
+}
+
+// setRegistration approves or rejects the pending registration of a
+// username, or of a key fingerprint when the username registered several
+// keys.
+func setRegistration(cmd *cobra.Command, repo repository.Repository, admin model.User, identifier string, status string) error {
+	pending, err := usersWithStatus(repo, userPending)
+	if err != nil {
+		return err
+	}
+
+	matching := matchUsers(pending, identifier)
+	switch len(matching) {
+	case 0:
+		return fmt.Errorf("No pending registration matches %s.", identifier)
+	case 1:
+	default:
+		return fmt.Errorf("Several pending registrations match %s, pick one by its fingerprint.", identifier)
+	}
+
+	user := matching[0]
+	user.Status = status
+	if err := repo.User().Update(&user); err != nil {
+		return err
+	}
+
+	verb, action := "Approved", model.EventUserApproved
+	if status == userRejected {
+		verb, action = "Rejected", model.EventUserRejected
+	}
+	recordAdminEvent(repo, admin.ID, action, "", user.ID)
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s the registration of %s (%s).\n", verb, user.Username, displayShareholder(user.PublicKey))
+	return nil
+}
+
+// setDisabled disables or enables a user, by username or key fingerprint.
+// Admins can't be disabled, they would be let in regardless.
+func setDisabled(cmd *cobra.Command, repo repository.Repository, admin model.User, identifier string, disabled bool) error {
+	users, err := repo.User().List()
+	if err != nil {
+		return err
+	}
+
+	matching := matchUsers(users, identifier)
+	switch len(matching) {
+	case 0:
+		return fmt.Errorf("No user matches %s.", identifier)
+	case 1:
+	default:
+		return fmt.Errorf("Several users match %s, pick one by its fingerprint.", identifier)
+	}
+
+	user := matching[0]
+	for _, key := range verifiedKeys(user) {
+		if isAdmin(key) {
+			return fmt.Errorf("%s is an admin, remove their keys from SSSS_ADMIN_KEYS first.", user.Username)
+		}
+	}
+
+	status, verb, action := userDisabled, "Disabled", model.EventUserDisabled
+	switch {
+	case disabled && user.Status == userDisabled:
+		return fmt.Errorf("%s is already disabled.", user.Username)
+	case !disabled && user.Status != userDisabled:
+		return fmt.Errorf("%s isn't disabled.", user.Username)
+	case !disabled:
+		status, verb, action = userActive, "Enabled", model.EventUserEnabled
+	}
+
+	user.Status = status
+	if err := repo.User().Update(&user); err != nil {
+		return err
+	}
+	recordAdminEvent(repo, admin.ID, action, "", user.ID)
+
+	log.Info(verb+" a user", "user.id", user.ID, "admin", admin.ID)
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s %s.\n", verb, user.Username)
+	return nil
+}
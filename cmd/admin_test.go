@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/spf13/viper"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// runSSHCmd runs the command the way executeSSHCmd does, as the user.
+func runSSHCmd(user model.User, args ...string) (string, error) {
+	sess := newTestSession(user.Username, nil, args...)
+	sess.ctx.SetValue(userContextKey{}, user)
+
+	out := &bytes.Buffer{}
+	rootCmd := NewSSHCmd(sess)
+	rootCmd.SetArgs(args)
+	rootCmd.SetIn(strings.NewReader(""))
+	rootCmd.SetOut(out)
+	rootCmd.SetErr(&bytes.Buffer{})
+
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+// userStatus reads the status of the user back from the repository.
+func userStatus(t *testing.T, repo repository.Repository, user model.User) string {
+	t.Helper()
+
+	got, err := repo.User().Get(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return got.Status
+}
+
+func TestAdminGate(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	admin, priv := testUser(t, repo, "gate-admin")
+	user, _ := testUser(t, repo, "gate-user")
+
+	pending := []model.User{}
+	for _, username := range []string{"gate-approved", "gate-rejected"} {
+		publicKey, _ := testKey(t)
+		u, err := repo.User().Upsert(&model.User{Username: username, PublicKey: publicKey, Status: userPending})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pending = append(pending, *u)
+	}
+
+	// A split running for the admins to kill
+	secret, ss, err := startSplit(repo, user, &model.Secret{Parts: 2, Threshold: 2}, "pw-user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+
+	viper.Set("admin_keys", string(gossh.MarshalAuthorizedKey(sshKey(t, priv.Public()))))
+	defer viper.Set("admin_keys", "")
+
+	// Users that aren't admins are refused every admin command, and change
+	// nothing
+	for _, args := range [][]string{
+		{"admin", "registrations"},
+		{"admin", "approve", "gate-approved"},
+		{"admin", "reject", "gate-rejected"},
+		{"admin", "users"},
+		{"admin", "users", "disable", "gate-user"},
+		{"admin", "users", "enable", "gate-user"},
+		{"admin", "secrets"},
+		{"admin", "ceremonies"},
+		{"admin", "kill", shortID(secret.ID)},
+		{"admin", "audit"},
+	} {
+		if _, err := runSSHCmd(user, args...); !errors.Is(err, errNotAdmin) {
+			t.Errorf("%s: err = %v, want %v", strings.Join(args, " "), err, errNotAdmin)
+		}
+	}
+	for _, u := range pending {
+		if status := userStatus(t, repo, u); status != userPending {
+			t.Fatalf("%s: status = %q, want %q", u.Username, status, userPending)
+		}
+	}
+	if status := userStatus(t, repo, user); status != userActive {
+		t.Fatalf("status = %q, want %q", status, userActive)
+	}
+	if _, ok := SplitStates.Get(secret.ID); !ok {
+		t.Fatal("split ceremony killed")
+	}
+
+	// Admins get through
+	out, err := runSSHCmd(admin, "admin", "registrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range pending {
+		if !strings.Contains(out, u.Username) {
+			t.Errorf("registrations don't list %s:\n%s", u.Username, out)
+		}
+	}
+
+	if out, err = runSSHCmd(admin, "admin", "users"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, user.Username) {
+		t.Errorf("users don't list %s:\n%s", user.Username, out)
+	}
+
+	if _, err := runSSHCmd(admin, "admin", "approve", "gate-approved"); err != nil {
+		t.Fatal(err)
+	}
+	if status := userStatus(t, repo, pending[0]); status != userActive {
+		t.Fatalf("status = %q, want %q", status, userActive)
+	}
+	if _, err := runSSHCmd(admin, "admin", "reject", "gate-rejected"); err != nil {
+		t.Fatal(err)
+	}
+	if status := userStatus(t, repo, pending[1]); status != userRejected {
+		t.Fatalf("status = %q, want %q", status, userRejected)
+	}
+
+	for _, args := range [][]string{
+		{"admin", "secrets"},
+		{"admin", "ceremonies"},
+		{"admin", "audit"},
+	} {
+		if _, err := runSSHCmd(admin, args...); err != nil {
+			t.Errorf("%s: %v", strings.Join(args, " "), err)
+		}
+	}
+
+	if _, err := runSSHCmd(admin, "admin", "users", "disable", "gate-user"); err != nil {
+		t.Fatal(err)
+	}
+	disabled, err := repo.User().Get(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := admitted(disabled); !errors.Is(err, errUserDisabled) {
+		t.Fatalf("err = %v, want %v", err, errUserDisabled)
+	}
+	if _, err := runSSHCmd(admin, "admin", "users", "enable", "gate-user"); err != nil {
+		t.Fatal(err)
+	}
+	if status := userStatus(t, repo, user); status != userActive {
+		t.Fatalf("status = %q, want %q", status, userActive)
+	}
+
+	if _, err := runSSHCmd(admin, "admin", "kill", shortID(secret.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := SplitStates.Get(secret.ID); ok {
+		t.Fatal("split ceremony still registered")
+	}
+}
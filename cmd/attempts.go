@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var errTooManyAttempts = errors.New("Too many wrong passphrases, try again later.")
+
+// unsignAttempts counts the wrong passphrases given to unsign the shares of
+// each user and secret. Every attempt costs an Argon2id derivation per share,
+// so guessing is throttled before any of them is run.
+var unsignAttempts = newAttempts()
+
+type attempts struct {
+	mu     sync.Mutex
+	failed map[string]failedAttempts
+}
+
+type failedAttempts struct {
+	count int
+	since time.Time
+}
+
+func newAttempts() *attempts {
+	return &attempts{failed: map[string]failedAttempts{}}
+}
+
+// Allow reports whether another attempt may be made, the failures counting
+// for SSSS_UNSIGN_LOCKOUT after the first of them.
+func (a *attempts) Allow(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, ok := a.failed[key]
+	if !ok {
+		return true
+	}
+	if time.Since(f.since) > viper.GetDuration("unsign_lockout") {
+		delete(a.failed, key)
+		return true
+	}
+
+	return f.count < viper.GetInt("unsign_attempts")
+}
+
+// Fail records a failed attempt.
+func (a *attempts) Fail(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, ok := a.failed[key]
+	if !ok || time.Since(f.since) > viper.GetDuration("unsign_lockout") {
+		f = failedAttempts{since: time.Now()}
+	}
+
+	f.count++
+	a.failed[key] = f
+}
+
+// Reset forgets the failed attempts after a successful one.
+func (a *attempts) Reset(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	delete(a.failed, key)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/log"
+)
+
+// EventOutput is the machine-readable representation of an audit event.
+type EventOutput struct {
+	Action    string    `json:"action" yaml:"action"`
+	User      string    `json:"user" yaml:"user"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+}
+
+// recordEvent appends an event to the audit log. Auditing never interrupts
+// a ceremony, so failures are only logged.
+func recordEvent(repo repository.Repository, secretID string, userID string, action string) {
+	if _, err := repo.Audit().Append(&model.Event{
+		Secret:    secretID,
+		User:      userID,
+		Action:    action,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		log.Error("Unable to record event", "action", action, "secret", secretID, "user", userID, "error", err)
+	}
+}
+
+func newEventOutputs(repo repository.Repository, events []model.Event) ([]EventOutput, error) {
+	usernames := map[string]string{}
+	outputs := make([]EventOutput, 0, len(events))
+	for _, e := range events {
+		if _, ok := usernames[e.User]; !ok {
+			user, err := repo.User().Get(e.User)
+			if err != nil {
+				return nil, err
+			}
+			usernames[e.User] = user.Username
+		}
+
+		outputs = append(outputs, EventOutput{
+			Action:    e.Action,
+			User:      usernames[e.User],
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	return outputs, nil
+}
+
+// AdminEventOutput is the machine-readable representation of an admin
+// action.
+type AdminEventOutput struct {
+	Action    string    `json:"action" yaml:"action"`
+	Admin     string    `json:"admin" yaml:"admin"`
+	Secret    string    `json:"secret,omitempty" yaml:"secret,omitempty"`
+	Subject   string    `json:"subject,omitempty" yaml:"subject,omitempty"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+}
+
+// adminActions are the actions only admins take.
+var adminActions = map[string]bool{
+	model.EventUserApproved:        true,
+	model.EventUserRejected:        true,
+	model.EventUserDisabled:        true,
+	model.EventUserEnabled:         true,
+	model.EventCeremonyKilled:      true,
+	model.EventSecretsListed:       true,
+	model.EventCeremoniesListed:    true,
+	model.EventUsersListed:         true,
+	model.EventRegistrationsListed: true,
+	model.EventAdminAuditListed:    true,
+}
+
+// recordAdminEvent appends an admin action to the audit log, along with the
+// secret or user it acted on, if any. Failures are only logged, like for
+// recordEvent.
+func recordAdminEvent(repo repository.Repository, adminID string, action string, secretID string, subjectID string) {
+	if _, err := repo.Audit().Append(&model.Event{
+		Secret:    secretID,
+		User:      adminID,
+		Subject:   subjectID,
+		Action:    action,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		log.Error("Unable to record event", "action", action, "secret", secretID, "user", adminID, "subject", subjectID, "error", err)
+	}
+}
+
+// newAdminEventOutputs lists the admin actions among the events.
+func newAdminEventOutputs(repo repository.Repository, events []model.Event) ([]AdminEventOutput, error) {
+	usernames := map[string]string{}
+	username := func(id string) (string, error) {
+		if _, ok := usernames[id]; !ok {
+			user, err := repo.User().Get(id)
+			if err != nil {
+				return "", err
+			}
+			usernames[id] = user.Username
+		}
+
+		return usernames[id], nil
+	}
+
+	outputs := []AdminEventOutput{}
+	for _, e := range events {
+		if !adminActions[e.Action] {
+			continue
+		}
+
+		o := AdminEventOutput{
+			Action:    e.Action,
+			Secret:    shortID(e.Secret),
+			CreatedAt: e.CreatedAt,
+		}
+
+		var err error
+		if o.Admin, err = username(e.User); err != nil {
+			return nil, err
+		}
+		if e.Subject != "" {
+			if o.Subject, err = username(e.Subject); err != nil {
+				return nil, err
+			}
+		}
+
+		outputs = append(outputs, o)
+	}
+
+	return outputs, nil
+}
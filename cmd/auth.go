@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/spf13/viper"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// defaultKeyTypes are the public key types users may authenticate with,
+// unless SSSS_KEY_TYPES says otherwise. Keys held on FIDO2 security keys are
+// accepted along with the usual ones.
+var defaultKeyTypes = []string{
+	gossh.KeyAlgoED25519,
+	gossh.KeyAlgoSKED25519,
+	gossh.KeyAlgoECDSA256,
+	gossh.KeyAlgoECDSA384,
+	gossh.KeyAlgoECDSA521,
+	gossh.KeyAlgoSKECDSA256,
+	gossh.KeyAlgoRSA,
+}
+
+// allowedKeyTypes reads the key type allowlist, given as a comma or space
+// separated list.
+func allowedKeyTypes() []string {
+	types := strings.FieldsFunc(viper.GetString("key_types"), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+	if len(types) == 0 {
+		return defaultKeyTypes
+	}
+
+	return types
+}
+
+// keyTypeAllowed reports whether the key's type is allowed.
+func keyTypeAllowed(key ssh.PublicKey) bool {
+	for _, t := range allowedKeyTypes() {
+		if key.Type() == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// publicKeyAuth accepts the public keys whose type is allowed, and that the
+// registration policy lets in.
+func publicKeyAuth(ctx ssh.Context, key ssh.PublicKey) bool {
+	if !keyTypeAllowed(key) {
+		log.Warn("Rejected a public key", "user", ctx.User(), "type", key.Type())
+		return false
+	}
+
+	if !registered(key) {
+		log.Warn("Rejected an unregistered public key", "user", ctx.User(), "fingerprint", gossh.FingerprintSHA256(key))
+		return false
+	}
+
+	return true
+}
+
+// userPublicKey encodes the key a user authenticated with the way
+// model.User.PublicKey stores it. Users authenticating with a certificate
+// are identified by the key it certifies, so renewing the certificate
+// doesn't make them someone else.
+func userPublicKey(key ssh.PublicKey) string {
+	if cert, ok := key.(*gossh.Certificate); ok {
+		key = cert.Key
+	}
+
+	return base64.StdEncoding.EncodeToString(key.Marshal())
+}
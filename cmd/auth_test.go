@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/spf13/viper"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// testContext is the context of a connection as the given username. Only
+// its values are kept, the rest of it panics when used.
+type testContext struct {
+	ssh.Context
+	user   string
+	values map[any]any
+}
+
+func newTestContext(user string) *testContext {
+	return &testContext{user: user, values: map[any]any{}}
+}
+
+func (c *testContext) User() string            { return c.user }
+func (c *testContext) Value(key any) any       { return c.values[key] }
+func (c *testContext) SetValue(key, value any) { c.values[key] = value }
+
+// sshKey converts a crypto public key into an SSH one.
+func sshKey(t *testing.T, pub any) ssh.PublicKey {
+	t.Helper()
+
+	key, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestPublicKeyAuth(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]ssh.PublicKey{
+		"ed25519": sshKey(t, edPub),
+		"p256":    sshKey(t, &p256.PublicKey),
+		"p384":    sshKey(t, &p384.PublicKey),
+		"rsa":     sshKey(t, &rsaKey.PublicKey),
+	}
+
+	defer viper.Set("key_types", "")
+	for _, c := range []struct {
+		keyTypes string
+		allowed  []string
+	}{
+		{"", []string{"ed25519", "p256", "p384", "rsa"}},
+		{"ssh-ed25519", []string{"ed25519"}},
+		{"ssh-ed25519, ecdsa-sha2-nistp256", []string{"ed25519", "p256"}},
+		{"ecdsa-sha2-nistp384,ssh-rsa", []string{"p384", "rsa"}},
+		{"ssh-dss", nil},
+	} {
+		viper.Set("key_types", c.keyTypes)
+
+		for name, key := range keys {
+			allowed := false
+			for _, a := range c.allowed {
+				allowed = allowed || a == name
+			}
+
+			if got := publicKeyAuth(newTestContext("alice"), key); got != allowed {
+				t.Errorf("SSSS_KEY_TYPES=%q: %s key allowed = %v, want %v", c.keyTypes, name, got, allowed)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+)
+
+// Every command that acts on a secret is authorized here, before it looks
+// at the secret's state:
+//   - its creator may combine, audit and manage it
+//   - its recipients may combine and audit it
+//   - its designated shareholders may sign it, anyone may when there are none
+//   - the holders of its current shares may unsign and audit it
+const (
+	actionSign    = "sign"
+	actionCombine = "combine"
+	actionUnsign  = "unsign"
+	actionAudit   = "audit"
+	actionReshare = "reshare"
+	actionRefresh = "refresh"
+	actionDelete  = "delete"
+	actionArchive = "archive"
+	actionRelabel = "relabel"
+)
+
+// permissionError explains why the user may not act on a secret.
+type permissionError struct {
+	Action string
+	Reason string
+}
+
+func (e *permissionError) Error() string {
+	return fmt.Sprintf("Permission denied: you may not %s this secret, %s.", e.Action, e.Reason)
+}
+
+// authorizedSecret looks up a secret and authorizes the user's action on it.
+func authorizedSecret(repo repository.Repository, user model.User, id string, action string) (*model.Secret, error) {
+	secret, err := repo.Secret().Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authorize(repo, user, secret, action); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// authorize checks that the user may take the action on the secret.
+func authorize(repo repository.Repository, user model.User, secret *model.Secret, action string) error {
+	owner := secret.User == user.ID
+
+	switch action {
+	case actionCombine:
+		if owner || isRecipient(secret, user) {
+			return nil
+		}
+		return &permissionError{action, "only its creator and recipients may"}
+	case actionSign:
+		// Reshares are signed by the shareholders of the new split
+		target := secret
+		if rs, ok := ReshareStates.Get(secret.ID); ok {
+			target = rs.Target
+		}
+		if canSign(target, user) {
+			return nil
+		}
+		return &permissionError{action, "you are not one of its designated shareholders"}
+	case actionUnsign:
+		holder, err := holdsShare(repo, secret, user)
+		if err != nil || holder {
+			return err
+		}
+		return &permissionError{action, "you don't hold any of its shares"}
+	case actionAudit:
+		if owner || isRecipient(secret, user) {
+			return nil
+		}
+		holder, err := holdsShare(repo, secret, user)
+		if err != nil || holder {
+			return err
+		}
+		return &permissionError{action, "only its creator, recipients and shareholders may"}
+	case actionReshare, actionRefresh, actionDelete, actionArchive, actionRelabel:
+		if owner {
+			return nil
+		}
+		return &permissionError{action, "only its creator may"}
+	}
+
+	return &permissionError{action, "the action is unknown"}
+}
+
+// isRecipient reports whether the user is one of the secret's recipients.
+func isRecipient(secret *model.Secret, user model.User) bool {
+	for _, id := range secret.Recipients {
+		if designates(secret, id, user) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// holdsShare reports whether the user holds one of the secret's current
+// shares.
+func holdsShare(repo repository.Repository, secret *model.Secret, user model.User) (bool, error) {
+	shares, err := repo.Share().MineForSecret(secret.ID, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return len(shares) > 0, nil
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+)
+
+func TestAuthorize(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	owner, _ := testUser(t, repo, "authorize-owner")
+	holder, _ := testUser(t, repo, "authorize-holder")
+	recipient, _ := testUser(t, repo, "authorize-recipient")
+	stranger, _ := testUser(t, repo, "authorize-stranger")
+
+	designated := testSplit(t, repo, &model.Secret{
+		Parts:        2,
+		Threshold:    2,
+		Shareholders: []string{owner.ID, holder.ID},
+		Recipients:   []string{recipient.ID},
+	}, []byte("secret"), []model.User{owner, holder}, []string{"pw-owner", "pw-holder"})
+
+	// Anyone may sign secrets without designated shareholders, or designated
+	// by one of their keys
+	open := testSplit(t, repo, &model.Secret{Parts: 1, Threshold: 1}, []byte("secret"),
+		[]model.User{owner}, []string{"pw-owner"})
+	byKey := testSplit(t, repo, &model.Secret{Parts: 1, Threshold: 1, Shareholders: []string{owner.ID, stranger.PublicKey}}, []byte("secret"),
+		[]model.User{owner}, []string{"pw-owner"})
+
+	for _, c := range []struct {
+		name    string
+		secret  *model.Secret
+		action  string
+		allowed []model.User
+		refused []model.User
+	}{
+		{"combine", designated, actionCombine, []model.User{owner, recipient}, []model.User{holder, stranger}},
+		{"sign", designated, actionSign, []model.User{owner, holder}, []model.User{recipient, stranger}},
+		{"sign open", open, actionSign, []model.User{owner, holder, recipient, stranger}, nil},
+		{"sign by key", byKey, actionSign, []model.User{owner, stranger}, []model.User{holder, recipient}},
+		{"unsign", designated, actionUnsign, []model.User{owner, holder}, []model.User{recipient, stranger}},
+		{"audit", designated, actionAudit, []model.User{owner, holder, recipient}, []model.User{stranger}},
+		{"reshare", designated, actionReshare, []model.User{owner}, []model.User{holder, recipient, stranger}},
+		{"refresh", designated, actionRefresh, []model.User{owner}, []model.User{holder, recipient, stranger}},
+		{"delete", designated, actionDelete, []model.User{owner}, []model.User{holder, recipient, stranger}},
+		{"archive", designated, actionArchive, []model.User{owner}, []model.User{holder, recipient, stranger}},
+		{"relabel", designated, actionRelabel, []model.User{owner}, []model.User{holder, recipient, stranger}},
+		{"unknown", designated, "steal", nil, []model.User{owner, holder, recipient, stranger}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			for _, user := range c.allowed {
+				secret, err := authorizedSecret(repo, user, shortID(c.secret.ID), c.action)
+				if err != nil {
+					t.Errorf("%s: %v", user.Username, err)
+				} else if secret.ID != c.secret.ID {
+					t.Errorf("%s: authorized %s, want %s", user.Username, secret.ID, c.secret.ID)
+				}
+			}
+
+			for _, user := range c.refused {
+				var denied *permissionError
+				if _, err := authorizedSecret(repo, user, shortID(c.secret.ID), c.action); !errors.As(err, &denied) {
+					t.Errorf("%s: err = %v, want a permission error", user.Username, err)
+				}
+			}
+		})
+	}
+
+	if _, err := authorizedSecret(repo, owner, "missing", actionCombine); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrNotFound)
+	}
+}
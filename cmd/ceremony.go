@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/log"
+)
+
+var (
+	errCeremonyExpired = errors.New("The ceremony timed out waiting for shareholders.")
+	errCeremonyClosed  = errors.New("The ceremony is no longer running.")
+	errCeremonyFull    = errors.New("The ceremony already has every share it needs.")
+	errAlreadySigned   = errors.New("You already signed your share of this secret.")
+	errAlreadyRotated  = errors.New("You already signed a refreshed share for each of your shares.")
+	errAlreadyUnsigned = errors.New("You already unsigned your share for this ceremony.")
+	errShareUnsigned   = errors.New("This share was already unsigned for this ceremony.")
+	errNoCeremony      = errors.New("No ceremony is running for this secret.")
+	errInCeremony      = errors.New("Secret is in the middle of a ceremony.")
+)
+
+// CeremonyOutput is the machine-readable representation of a running
+// ceremony. Signed and Unsigned count the signatures and shares received out
+// of the ones expected, for the halves of the ceremony that take them.
+type CeremonyOutput struct {
+	ID        string    `json:"id" yaml:"id"`
+	Label     string    `json:"label" yaml:"label"`
+	Ceremony  string    `json:"ceremony" yaml:"ceremony"`
+	Initiator string    `json:"initiator" yaml:"initiator"`
+	Signed    string    `json:"signed,omitempty" yaml:"signed,omitempty"`
+	Unsigned  string    `json:"unsigned,omitempty" yaml:"unsigned,omitempty"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+}
+
+// sharesPerUser is how many shares of the secret one user may hold, or 0
+// when the secret lets them hold any number.
+func sharesPerUser(secret *model.Secret) int {
+	if secret.MultipleShares {
+		return 0
+	}
+
+	return 1
+}
+
+// ExpireCeremonies cleans up ceremonies orphaned by a previous run of the
+// server. Passphrases and decrypted shares only ever live in memory, so an
+// interrupted ceremony can't be resumed: splits are marked dead, and combines
+// and reshares are discarded, leaving their secret ready to be combined again.
+func ExpireCeremonies(repo repository.Repository) error {
+	ceremonies, err := repo.Ceremony().All()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range ceremonies {
+		log.Warn("Expiring orphaned ceremony", "id", c.ID, "kind", c.Kind, "secret", c.Secret, "participants", len(c.Participants), "expected", c.Expected)
+		if err := repo.Ceremony().Delete(c.ID); err != nil {
+			return err
+		}
+
+		// Expired splits are audited along with their secret below
+		if c.Kind == "combine" {
+			recordEvent(repo, c.Secret, c.User, model.EventCombineCancelled)
+		}
+	}
+
+	// Any secret still signing has lost its split ceremony
+	secrets, err := repo.Secret().WithStatus("signing")
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range secrets {
+		log.Warn("Expiring orphaned secret", "id", secret.ID)
+		secret.Status = "dead"
+		if err := repo.Secret().Update(&secret); err != nil {
+			return err
+		}
+
+		recordEvent(repo, secret.ID, secret.User, model.EventSplitCancelled)
+	}
+
+	// Reshared and refreshed secrets keep the shares of their generation:
+	// the new ones when the secret was already pointed at them, its previous
+	// ones otherwise
+	for status, action := range map[string]string{
+		"resharing":  model.EventReshareCancelled,
+		"refreshing": model.EventRefreshCancelled,
+	} {
+		secrets, err := repo.Secret().WithStatus(status)
+		if err != nil {
+			return err
+		}
+
+		for _, secret := range secrets {
+			log.Warn("Expiring orphaned "+status+" secret", "id", secret.ID)
+			if err := revokeStaleShares(repo, &secret); err != nil {
+				return err
+			}
+
+			secret.Status = "ready"
+			if err := repo.Secret().Update(&secret); err != nil {
+				return err
+			}
+
+			recordEvent(repo, secret.ID, secret.User, action)
+		}
+	}
+
+	return nil
+}
+
+// inCeremony reports whether a ceremony of the secret is running.
+func inCeremony(secret *model.Secret) bool {
+	if _, ok := CombineStates.Get(secret.ID); ok {
+		return true
+	}
+
+	switch secret.Status {
+	case "signing", "resharing", "refreshing":
+		return true
+	}
+
+	return false
+}
+
+// ceremonies lists every running ceremony, soonest to expire first. The two
+// halves of a reshare or refresh are listed as one ceremony.
+func ceremonies(repo repository.Repository) ([]CeremonyOutput, error) {
+	outputs := []CeremonyOutput{}
+	add := func(id string, kind string, ceremony *model.Ceremony, ss *SplitState, cs *CombineState) error {
+		secret, err := repo.Secret().Get(shortID(id))
+		if err != nil {
+			return err
+		}
+
+		initiator, err := repo.User().Get(ceremony.User)
+		if err != nil {
+			return err
+		}
+
+		o := CeremonyOutput{
+			ID:        shortID(id),
+			Label:     secret.Label,
+			Ceremony:  kind,
+			Initiator: initiator.Username,
+			ExpiresAt: ceremony.ExpiresAt,
+		}
+		if ss != nil {
+			o.Signed = fmt.Sprintf("%d/%d", ss.Len(), ss.Expected)
+		}
+		if cs != nil {
+			o.Unsigned = fmt.Sprintf("%d/%d", cs.Len(), cs.Expected)
+		}
+
+		outputs = append(outputs, o)
+		return nil
+	}
+
+	running := snapshotCeremonies()
+	for id, rs := range running.reshares {
+		kind := "reshare"
+		if rs.Refresh {
+			kind = "refresh"
+		}
+		if err := add(id, kind, rs.Combine.ceremony, rs.Split, rs.Combine); err != nil {
+			return nil, err
+		}
+	}
+
+	for id, ss := range running.splits {
+		if _, ok := running.reshares[id]; ok {
+			continue
+		}
+		if err := add(id, "split", ss.ceremony, ss, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	for id, cs := range running.combines {
+		if _, ok := running.reshares[id]; ok {
+			continue
+		}
+		if err := add(id, "combine", cs.ceremony, nil, cs); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].ExpiresAt.Before(outputs[j].ExpiresAt)
+	})
+
+	return outputs, nil
+}
+
+// killCeremony tears down the ceremony running for the secret, the way its
+// initiator cancelling it would, and returns what kind of ceremony it was.
+// Whoever is waiting on the ceremony is told it is no longer running.
+func killCeremony(repo repository.Repository, secret *model.Secret) (string, error) {
+	if rs, ok := ReshareStates.Get(secret.ID); ok {
+		kind := "reshare"
+		if rs.Refresh {
+			kind = "refresh"
+		}
+
+		return kind, abortReshare(repo, secret, rs, false)
+	}
+
+	if ss, ok := SplitStates.Get(secret.ID); ok {
+		return "split", abortSplit(repo, secret, ss)
+	}
+
+	if cs, ok := CombineStates.Get(secret.ID); ok {
+		recordEvent(repo, secret.ID, cs.ceremony.User, model.EventCombineCancelled)
+		return "combine", cs.Close()
+	}
+
+	return "", errNoCeremony
+}
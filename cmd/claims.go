@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// keyClaims holds the keys added with "keys add" until a session
+// authenticated with them claims them, proving it holds their private key.
+var keyClaims = newClaims()
+
+type keyClaim struct {
+	UserID    string
+	Username  string
+	PublicKey string
+	ExpiresAt time.Time
+	Redeemed  bool
+}
+
+type claims struct {
+	mu     sync.Mutex
+	claims map[string]*keyClaim
+}
+
+func newClaims() *claims {
+	return &claims{claims: map[string]*keyClaim{}}
+}
+
+// Add opens a claim on the key, valid for SSSS_KEY_CLAIM_TIMEOUT, and
+// returns its token.
+func (c *claims) Add(userId, username, publicKey string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for t, claim := range c.claims {
+		if time.Now().After(claim.ExpiresAt) {
+			delete(c.claims, t)
+		}
+	}
+
+	c.claims[token] = &keyClaim{
+		UserID:    userId,
+		Username:  username,
+		PublicKey: publicKey,
+		ExpiresAt: time.Now().Add(viper.GetDuration("key_claim_timeout")),
+	}
+
+	return token, nil
+}
+
+// Redeem returns the claim of the token, when it was opened on the key by
+// the user connected as username and hasn't expired or been redeemed.
+func (c *claims) Redeem(token, username, publicKey string) (*keyClaim, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	claim, ok := c.claims[token]
+	if !ok || claim.Redeemed || time.Now().After(claim.ExpiresAt) {
+		return nil, false
+	}
+	if claim.Username != username || claim.PublicKey != publicKey {
+		return nil, false
+	}
+
+	claim.Redeemed = true
+	return claim, true
+}
+
+// Redeemed reports whether the token's claim on the key was redeemed, and
+// forgets it.
+func (c *claims) Redeemed(token, publicKey string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	claim, ok := c.claims[token]
+	if !ok || !claim.Redeemed || claim.PublicKey != publicKey {
+		return false
+	}
+
+	delete(c.claims, token)
+	return true
+}
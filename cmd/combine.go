@@ -1,39 +1,86 @@
 package cmd
 
 import (
+	"github.com/adamgoose/ssss/lib/model"
 	"github.com/adamgoose/ssss/lib/repository"
 	"github.com/charmbracelet/bubbles/progress"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/ssh"
-	"github.com/corvus-ch/shamir"
 )
 
-func RunCombineProgram(s ssh.Session, repo repository.Repository, cs *CombineState) error {
+// combineShares recovers the secret from the received shares and closes the
+// combine ceremony.
+func combineShares(cs *CombineState) ([]byte, error) {
+	defer cs.Close()
+
+	secret, err := recoverSecret(cs)
+	if err != nil {
+		return nil, err
+	}
+
+	recordEvent(cs.repo, cs.SecretID, cs.ceremony.User, model.EventSecretRecovered)
+	return secret, nil
+}
+
+// recoverSecret interpolates the received shares. Secrets split with a
+// digest are only returned once they are verified against it.
+func recoverSecret(cs *CombineState) ([]byte, error) {
+	shares := map[byte][]byte{}
+	for _, share := range cs.Shares {
+		shares[share.Key] = share.Share
+	}
+
+	secret, err := combineSecret(cs.secret, shares)
+	if err == nil && len(cs.secret.Digest) > 0 && !checkDigest(cs.secret.Digest, secret) {
+		err = errIncorrectSecret
+	}
+	if err != nil {
+		log.Error("Unable to recover secret", "id", cs.SecretID, "error", err)
+		recordEvent(cs.repo, cs.SecretID, cs.ceremony.User, model.EventRecoveryFailed)
+		return nil, errIncorrectSecret
+	}
+
+	return secret, nil
+}
+
+// expireCombine tears down a combine ceremony whose deadline passed. The
+// secret stays ready to be combined again.
+func expireCombine(cs *CombineState) error {
+	log.Info("Combine ceremony expired", "id", cs.SecretID, "unsigned", cs.Len(), "expected", cs.Expected)
+
+	recordEvent(cs.repo, cs.SecretID, cs.ceremony.User, model.EventCombineExpired)
+	return cs.Close()
+}
+
+// abortCombine tears down a combine ceremony its initiator cancelled.
+func abortCombine(cs *CombineState) error {
+	recordEvent(cs.repo, cs.SecretID, cs.ceremony.User, model.EventCombineCancelled)
+	return cs.Close()
+}
+
+func RunCombineProgram(s ssh.Session, repo repository.Repository, secret *model.Secret, cs *CombineState) error {
 	pty, _, ok := s.Pty()
+	if !ok {
+		return errNoTerminal
+	}
 
-	combineTUI := CombineTUI{
-		TUI:          NewTUI(s),
-		repo:         repo,
-		progress:     progress.New(progress.WithWidth(pty.Window.Width-2), progress.WithoutPercentage()),
-		combineState: cs,
+	holders, err := shareHolders(repo, secret)
+	if err != nil {
+		return err
 	}
 
-	var p *tea.Program
-	if !ok || s.EmulatedPty() {
-		p = tea.NewProgram(combineTUI,
-			tea.WithInput(s),
-			tea.WithOutput(s),
-		)
-	} else {
-		p = tea.NewProgram(combineTUI,
-			tea.WithInput(pty.Slave),
-			tea.WithOutput(pty.Slave),
-		)
+	combineTUI := CombineTUI{
+		TUI:           NewTUI(s),
+		repo:          repo,
+		progress:      progress.New(progress.WithWidth(pty.Window.Width-2), progress.WithoutPercentage()),
+		combineState:  cs,
+		holders:       holders,
+		unsignCommand: unsignCommand(secret, false),
 	}
 
-	_, err := p.Run()
+	_, err = newProgram(s, combineTUI).Run()
 	return err
 }
 
@@ -42,8 +89,13 @@ type CombineTUI struct {
 	repo     repository.Repository
 	progress progress.Model
 
-	combineState *CombineState
-	secret       *[]byte
+	combineState  *CombineState
+	holders       map[string]string
+	unsignCommand string
+	secret        *[]byte
+	err           error
+	expired       bool
+	rejected      []string
 }
 
 func (t CombineTUI) Init() tea.Cmd {
@@ -66,24 +118,30 @@ func (t CombineTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Wait for another one
 		return t, receive(t.combineState)
+	case rejectedMsg:
+		t.rejected = append(t.rejected, msg.username)
+		return t, receive(t.combineState)
+	case expiredMsg:
+		expireCombine(t.combineState)
+		t.expired = true
+		return t, tea.Quit
+	case closedMsg:
+		t.err = errCeremonyClosed
+		return t, tea.Quit
 	case receivedAllMsg:
-		shares := map[byte][]byte{}
-		for _, share := range t.combineState.Shares {
-			shares[share.Key] = share.Share
-		}
-
-		v, err := shamir.Combine(shares)
-		if err == nil {
+		v, err := combineShares(t.combineState)
+		if err != nil {
+			t.err = err
+		} else {
 			t.secret = &v
 		}
 
-		delete(CombineStates, t.combineState.SecretID)
 		return t, tea.Quit
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c":
 			if t.combineState != nil {
-				delete(CombineStates, t.combineState.SecretID)
+				abortCombine(t.combineState)
 			}
 		}
 	}
@@ -103,13 +161,28 @@ func (t CombineTUI) View() string {
 	v := NewView()
 
 	if t.secret != nil {
-		v.WriteString("Your secret is: ")
-		v.Colorf(lipgloss.Color("#0F0"), string(*t.secret))
+		if len(t.combineState.secret.Digest) > 0 {
+			v.WriteString("Your secret was recovered and verified: ")
+		} else {
+			v.WriteString("Your secret is: ")
+		}
+		v.Colorf(lipgloss.Color("#0F0"), "%s", string(*t.secret))
+	} else if t.err != nil {
+		v.Colorf(lipgloss.Color("#F00"), "%s", t.err.Error())
+	} else if t.expired {
+		v.Colorf(lipgloss.Color("#F00"), errCeremonyExpired.Error())
 	} else {
 		v.WriteString("Ask others to unsign their shares with: ")
-		v.Colorf(lipgloss.Color("#0F0"), "ssh -t enge.me -- unsign %s", t.combineState.SecretID[8:])
+		v.Colorf(lipgloss.Color("#0F0"), "%s", t.unsignCommand)
 		v.NL()
 		v.WriteString(t.progress.ViewAs(float64(t.combineState.Len()) / float64(t.combineState.Expected)))
+		v.NL()
+		writeRoster(v, t.combineState.Participants(), t.combineState.Expected, pendingHolders(t.combineState, t.holders))
+
+		for _, username := range t.rejected {
+			v.NL()
+			v.Colorf(lipgloss.Color("#F00"), "Rejected a share from %s that failed verification", username)
+		}
 	}
 
 	return t.renderer.NewStyle().Width(t.width-2).Border(lipgloss.RoundedBorder(), true).Render(v.String()) + "\n"
@@ -1,70 +1,239 @@
 package cmd
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+	"time"
 
-var CombineStates = make(map[string]*CombineState)
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/log"
+	"github.com/spf13/viper"
+)
+
+var CombineStates = newRegistry[*CombineState]()
 
 type ShamirShare struct {
+	UserID   string
+	Username string
+
 	Key   byte
 	Share []byte
 }
 
-func NewCombineState(secretId string, expected int) *CombineState {
+// shareRejectedError reports a share that failed verification, naming the
+// shareholder who unsigned it.
+type shareRejectedError struct {
+	Username string
+}
+
+func (e *shareRejectedError) Error() string {
+	return fmt.Sprintf("The share unsigned by %s failed verification and was rejected.", e.Username)
+}
+
+// NewCombineState opens a combine ceremony expecting the given number of
+// shares, of which each user may unsign up to perUser, or any number when it
+// is 0.
+func NewCombineState(repo repository.Repository, secret *model.Secret, userId string, expected int, perUser int) (*CombineState, error) {
+	secretId := secret.ID
+	deadline := time.Now().Add(viper.GetDuration("combine_timeout"))
+	ceremony, err := repo.Ceremony().Create(&model.Ceremony{
+		Secret:       secretId,
+		User:         userId,
+		Kind:         "combine",
+		Expected:     expected,
+		Participants: make([]model.Participant, 0),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		ExpiresAt:    deadline,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	s := &CombineState{
-		SecretID: secretId,
-		Expected: expected,
-		Shares:   make([]ShamirShare, 0),
-		chanS:    make(chan ShamirShare, expected),
-		chanDone: make(chan error, 1),
+		repo:       repo,
+		ceremony:   ceremony,
+		secret:     secret,
+		SecretID:   secretId,
+		Expected:   expected,
+		PerUser:    perUser,
+		Deadline:   deadline,
+		Shares:     make([]ShamirShare, 0),
+		unsigned:   map[string]int{},
+		keys:       map[byte]bool{},
+		chanS:      make(chan ShamirShare, expected),
+		chanR:      make(chan string, expected),
+		chanClosed: make(chan struct{}),
 	}
 
-	CombineStates[secretId] = s
-	return s
+	if !CombineStates.Add(secretId, s) {
+		repo.Ceremony().Delete(ceremony.ID)
+		return nil, errInCeremony
+	}
+
+	recordEvent(repo, secretId, userId, model.EventCombineStarted)
+	return s, nil
 }
 
 type CombineState struct {
-	mu       sync.Mutex
-	chanS    chan ShamirShare
-	chanDone chan error
+	mu         sync.Mutex
+	chanS      chan ShamirShare
+	chanR      chan string
+	chanClosed chan struct{}
+
+	repo     repository.Repository
+	ceremony *model.Ceremony
+	secret   *model.Secret
+	closed   bool
+
+	// unsigned counts the shares pushed by each user, and keys holds their x
+	// coordinates, including the ones not received yet
+	unsigned map[string]int
+	keys     map[byte]bool
 
 	SecretID string
 	Expected int
+	PerUser  int
+	Deadline time.Time
 	Shares   []ShamirShare
 }
 
 func (c *CombineState) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	return len(c.Shares)
 }
 
-func (c *CombineState) Push(s ShamirShare) {
-	c.chanS <- s
-}
+// Push hands a decrypted share to the ceremony. Shares beyond the expected
+// ones, shares already pushed, and shareholders beyond their own share of
+// them are turned away.
+func (c *CombineState) Push(s ShamirShare) error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
 
-func (c *CombineState) Receive() {
-	go func() {
-		c.mu.Lock()
-		defer c.mu.Unlock()
+	if closed {
+		return errCeremonyClosed
+	}
+	if time.Now().After(c.Deadline) {
+		return errCeremonyExpired
+	}
+
+	// Reject shares that don't match the secret's commitments, and let the
+	// ceremony know who sent them
+	if err := verifyShare(c.secret, s.Key, s.Share); err != nil {
+		log.Warn("Rejected a share", "id", c.SecretID, "user", s.UserID, "error", err)
+		recordEvent(c.repo, c.SecretID, s.UserID, model.EventShareRejected)
 
-		for i := 0; i < c.Expected; i++ {
-			s := <-c.chanS
-			c.Shares = append(c.Shares, s)
+		select {
+		case c.chanR <- s.Username:
+		default:
 		}
 
-		c.chanDone <- nil
-	}()
+		return &shareRejectedError{Username: s.Username}
+	}
+
+	c.mu.Lock()
+	var err error
+	switch {
+	case c.closed:
+		err = errCeremonyClosed
+	case len(c.keys) >= c.Expected:
+		err = errCeremonyFull
+	case c.keys[s.Key]:
+		err = errShareUnsigned
+	case c.PerUser > 0 && c.unsigned[s.UserID] >= c.PerUser:
+		err = errAlreadyUnsigned
+	default:
+		c.unsigned[s.UserID]++
+		c.keys[s.Key] = true
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	c.chanS <- s
+	return nil
+}
+
+// Included reports whether the share with the given x coordinate was pushed.
+func (c *CombineState) Included(key byte) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.keys[key]
 }
 
-func (c *CombineState) ReceiveOne() error {
-	s := <-c.chanS
+// ReceiveOne waits for the next share, and returns who unsigned it.
+func (c *CombineState) ReceiveOne() (model.Participant, error) {
+	var s ShamirShare
+	select {
+	case s = <-c.chanS:
+	case username := <-c.chanR:
+		return model.Participant{}, &shareRejectedError{Username: username}
+	case <-c.chanClosed:
+		return model.Participant{}, errCeremonyClosed
+	case <-time.After(time.Until(c.Deadline)):
+		return model.Participant{}, errCeremonyExpired
+	}
 
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	c.Shares = append(c.Shares, s)
 
-	if len(c.Shares) == c.Expected {
-		c.chanDone <- nil
+	recordEvent(c.repo, c.SecretID, s.UserID, model.EventShareUnsigned)
+
+	// Only the participant is persisted, never the decrypted share
+	participant := model.Participant{
+		User:     s.UserID,
+		Username: s.Username,
+		JoinedAt: time.Now(),
 	}
+	c.ceremony.Participants = append(c.ceremony.Participants, participant)
+	c.ceremony.UpdatedAt = time.Now()
 
-	return nil
+	return participant, c.repo.Ceremony().Update(c.ceremony)
+}
+
+// Participants lists who unsigned so far, and when.
+func (c *CombineState) Participants() []model.Participant {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]model.Participant{}, c.ceremony.Participants...)
+}
+
+// UnsignedBy counts the shares the user unsigned so far.
+func (c *CombineState) UnsignedBy(userId string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.unsigned[userId]
+}
+
+// UnsignedAll reports whether the user unsigned every share they may for
+// this ceremony.
+func (c *CombineState) UnsignedAll(userId string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.PerUser > 0 && c.unsigned[userId] >= c.PerUser
+}
+
+// Close tears down the combine state and its persisted ceremony.
+func (c *CombineState) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.chanClosed)
+	}
+	c.mu.Unlock()
+
+	CombineStates.Delete(c.SecretID, c)
+	return c.repo.Ceremony().Delete(c.ceremony.ID)
 }
@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
@@ -9,11 +12,117 @@ import (
 	"github.com/charmbracelet/ssh"
 )
 
-func RunUnsignProgram(s ssh.Session, cs *CombineState, shares []model.Share) error {
+var errNoShare = errors.New("None of your shares could be unsigned.")
+
+// unsignShare decrypts the first of the user's shares that the combine
+// ceremony hasn't received yet and pushes it. Wrong passphrases are counted,
+// and refused once the user gave too many of them.
+func unsignShare(repo repository.Repository, cs *CombineState, user model.User, shares []model.Share, passphrase string) error {
+	if cs.UnsignedAll(user.ID) {
+		return errAlreadyUnsigned
+	}
+
+	attempt := user.ID + "/" + cs.SecretID
+	if !unsignAttempts.Allow(attempt) {
+		return errTooManyAttempts
+	}
+
+	var shamirShare *ShamirShare
+	tried := false
+	for _, share := range shares {
+		if cs.Included(share.Key) {
+			continue
+		}
+
+		tried = true
+		cipher, err := decrypt(share.Share, passphrase)
+		if err != nil {
+			continue
+		}
+		if err := verifyShare(cs.secret, share.Key, cipher); err != nil {
+			log.Warn("Share decrypted but failed verification", "id", share.ID)
+			continue
+		}
+
+		// Re-wrap shares encrypted with an outdated format
+		if ciphertextVersion(share.Share) != currentCipherVersion {
+			if err := rewrapShare(repo, share, cipher, passphrase); err != nil {
+				log.Error("Unable to re-wrap share", "id", share.ID, "error", err)
+			}
+		}
+
+		shamirShare = &ShamirShare{
+			UserID:   user.ID,
+			Username: user.Username,
+			Key:      share.Key,
+			Share:    cipher,
+		}
+		break
+	}
+
+	if shamirShare == nil {
+		if tried {
+			unsignAttempts.Fail(attempt)
+		}
+		return errNoShare
+	}
+
+	unsignAttempts.Reset(attempt)
+	return cs.Push(*shamirShare)
+}
+
+// pushUnwrappedShare pushes one of the user's shares that was decrypted
+// client side and that the combine ceremony hasn't received yet.
+func pushUnwrappedShare(cs *CombineState, user model.User, shares []model.Share, unwrapped map[string][]byte) error {
+	if cs.UnsignedAll(user.ID) {
+		return errAlreadyUnsigned
+	}
+
+	for _, share := range shares {
+		plaintext, ok := unwrapped[share.ID]
+		if !ok {
+			continue
+		}
+
+		if cs.Included(share.Key) {
+			continue
+		}
+
+		return cs.Push(ShamirShare{
+			UserID:   user.ID,
+			Username: user.Username,
+			Key:      share.Key,
+			Share:    plaintext,
+		})
+	}
+
+	return errNoShare
+}
+
+func rewrapShare(repo repository.Repository, share model.Share, plaintext []byte, passphrase string) error {
+	enc, err := encrypt(plaintext, passphrase)
+	if err != nil {
+		return err
+	}
+
+	share.Share = enc
+	if err := repo.Share().Update(&share); err != nil {
+		return err
+	}
+
+	log.Info("Re-wrapped share", "id", share.ID)
+	return nil
+}
+
+func RunUnsignProgram(s ssh.Session, repo repository.Repository, cs *CombineState, shares []model.Share) error {
 	pty, _, ok := s.Pty()
+	if !ok {
+		return errNoTerminal
+	}
 
 	unsignTUI := UnsignTUI{
 		TUI:          NewTUI(s),
+		repo:         repo,
 		shares:       shares,
 		combineState: cs,
 	}
@@ -28,29 +137,18 @@ func RunUnsignProgram(s ssh.Session, cs *CombineState, shares []model.Share) err
 		WithWidth(pty.Window.Width).
 		WithShowHelp(true)
 
-	var p *tea.Program
-	if !ok || s.EmulatedPty() {
-		p = tea.NewProgram(unsignTUI,
-			tea.WithInput(s),
-			tea.WithOutput(s),
-		)
-	} else {
-		p = tea.NewProgram(unsignTUI,
-			tea.WithInput(pty.Slave),
-			tea.WithOutput(pty.Slave),
-		)
-	}
-
-	_, err := p.Run()
+	_, err := newProgram(s, unsignTUI).Run()
 	return err
 }
 
 type UnsignTUI struct {
 	TUI
+	repo repository.Repository
 	form *huh.Form
 
 	shares       []model.Share
 	combineState *CombineState
+	err          error
 }
 
 func (t UnsignTUI) Init() tea.Cmd {
@@ -75,32 +173,9 @@ func (t UnsignTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	}
 
 	if t.form.State == huh.StateCompleted {
-		var shamirShare *ShamirShare
-		for _, share := range t.shares {
-			cipher, err := decrypt(share.Share, t.form.GetString("passphrase"))
-			if err != nil {
-				continue
-			}
-
-			included := false
-			for _, ss := range t.combineState.Shares {
-				if ss.Key == share.Key {
-					included = true
-				}
-			}
-			if included {
-				continue
-			}
-
-			shamirShare = &ShamirShare{
-				Key:   share.Key,
-				Share: cipher,
-			}
-		}
-
-		if shamirShare != nil {
+		t.err = unsignShare(t.repo, t.combineState, t.user, t.shares, t.form.GetString("passphrase"))
+		if t.err == nil {
 			log.Info("Pushing valid shamir share")
-			t.combineState.Push(*shamirShare)
 		}
 
 		return t, tea.Quit
@@ -112,7 +187,9 @@ func (t UnsignTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (t UnsignTUI) View() string {
 	v := NewView()
 
-	if t.form.State == huh.StateCompleted {
+	if t.err != nil {
+		v.Colorf(lipgloss.Color("#F00"), "%s", t.err)
+	} else if t.form.State == huh.StateCompleted {
 		v.Colorf(lipgloss.Color("#0F0"), "You unsigned the secret!")
 	} else {
 		v.Colorf(lipgloss.Color("#0F0"), "You are unsigning the secret!")
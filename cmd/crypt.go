@@ -1,23 +1,54 @@
 package cmd
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
 	"io"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Ciphertexts are prefixed with a magic header followed by a version byte.
+// Version 0 ciphertexts predate the header and are just nonce || ciphertext,
+// encrypted with a key derived by a single round of SHA-256.
+const cipherMagic = "ssss"
+
+const (
+	cipherV0 byte = iota
+	cipherV1
+
+	currentCipherVersion = cipherV1
+)
+
+// Argon2id parameters for version 1 ciphertexts
+const (
+	saltSize      = 16
+	argon2Time    = 1
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
 )
 
-func deriveKey(passphrase string) []byte {
+var errCiphertextTooShort = errors.New("ciphertext too short")
+
+var errIncorrectSecret = errors.New("Recovery produced an incorrect secret.")
+
+func deriveKeyV0(passphrase string) []byte {
 	sum := sha256.New()
 	sum.Write([]byte(passphrase))
 	return sum.Sum(nil)
 }
 
-func encrypt(plaintext []byte, passphrase string) ([]byte, error) {
-	// Derive the key from the passphrase
-	key := deriveKey(passphrase)
+func deriveKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+}
 
+func newGCM(key []byte) (cipher.AEAD, error) {
 	// Create a new Cipher Block from the key
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -26,7 +57,26 @@ func encrypt(plaintext []byte, passphrase string) ([]byte, error) {
 
 	// Create a new GCM - https://en.wikipedia.org/wiki/Galois/Counter_Mode
 	// https://golang.org/pkg/crypto/cipher/#NewGCM
-	aesGCM, err := cipher.NewGCM(block)
+	return cipher.NewGCM(block)
+}
+
+// ciphertextVersion reports the format version of an encrypted share.
+func ciphertextVersion(enctext []byte) byte {
+	if len(enctext) > len(cipherMagic) && bytes.HasPrefix(enctext, []byte(cipherMagic)) {
+		return enctext[len(cipherMagic)]
+	}
+
+	return cipherV0
+}
+
+func encrypt(plaintext []byte, passphrase string) ([]byte, error) {
+	// Generate a random salt and derive the key from the passphrase
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	aesGCM, err := newGCM(deriveKey(passphrase, salt))
 	if err != nil {
 		return nil, err
 	}
@@ -37,28 +87,63 @@ func encrypt(plaintext []byte, passphrase string) ([]byte, error) {
 		return nil, err
 	}
 
-	// Encrypt the data using aesGCM.Seal
-	// Since we don't want to save the nonce somewhere else in this case, we add it as a prefix to the encrypted data. The first nonce argument in Seal is the prefix.
-	return aesGCM.Seal(nonce, nonce, plaintext, nil), nil
+	// The header, salt and nonce are stored in front of the encrypted data.
+	// The header is also authenticated as additional data.
+	header := append([]byte(cipherMagic), cipherV1)
+	out := bytes.Join([][]byte{header, salt, nonce}, nil)
+
+	return aesGCM.Seal(out, nonce, plaintext, header), nil
 }
 
 func decrypt(enctext []byte, passphrase string) ([]byte, error) {
-	key := deriveKey(passphrase)
+	if ciphertextVersion(enctext) == cipherV1 {
+		plaintext, err := decryptV1(enctext, passphrase)
+		if err == nil {
+			return plaintext, nil
+		}
+	}
 
-	// Create a new Cipher Block from the key
-	block, err := aes.NewCipher(key)
+	// Headerless ciphertexts may happen to start with the magic, so anything
+	// that fails to open as v1 is retried as v0.
+	return decryptV0(enctext, passphrase)
+}
+
+func decryptV1(enctext []byte, passphrase string) ([]byte, error) {
+	headerSize := len(cipherMagic) + 1
+	if len(enctext) < headerSize+saltSize {
+		return nil, errCiphertextTooShort
+	}
+
+	header, salt := enctext[:headerSize], enctext[headerSize:headerSize+saltSize]
+
+	aesGCM, err := newGCM(deriveKey(passphrase, salt))
 	if err != nil {
 		return nil, err
 	}
 
-	// Create a new GCM
-	aesGCM, err := cipher.NewGCM(block)
+	// Extract the nonce from the encrypted data
+	rest := enctext[headerSize+saltSize:]
+	nonceSize := aesGCM.NonceSize()
+	if len(rest) < nonceSize {
+		return nil, errCiphertextTooShort
+	}
+	nonce, ciphertext := rest[:nonceSize], rest[nonceSize:]
+
+	// Decrypt the data
+	return aesGCM.Open(nil, nonce, ciphertext, header)
+}
+
+func decryptV0(enctext []byte, passphrase string) ([]byte, error) {
+	aesGCM, err := newGCM(deriveKeyV0(passphrase))
 	if err != nil {
 		return nil, err
 	}
 
 	// Get the nonce size
 	nonceSize := aesGCM.NonceSize()
+	if len(enctext) < nonceSize {
+		return nil, errCiphertextTooShort
+	}
 
 	// Extract the nonce from the encrypted data
 	nonce, ciphertext := enctext[:nonceSize], enctext[nonceSize:]
@@ -66,3 +151,25 @@ func decrypt(enctext []byte, passphrase string) ([]byte, error) {
 	// Decrypt the data
 	return aesGCM.Open(nil, nonce, ciphertext, nil)
 }
+
+// digestSecret returns salt || argon2id(plaintext, salt), a commitment to the
+// secret that lets a combine check it recovered the original. The digest is
+// as costly to brute force as a passphrase encrypted share.
+func digestSecret(plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	return append(salt, deriveKey(string(plaintext), salt)...), nil
+}
+
+// checkDigest reports whether the plaintext matches a digestSecret digest.
+func checkDigest(digest, plaintext []byte) bool {
+	if len(digest) != saltSize+argon2KeyLen {
+		return false
+	}
+
+	salt := digest[:saltSize]
+	return subtle.ConstantTimeCompare(digest[saltSize:], deriveKey(string(plaintext), salt)) == 1
+}
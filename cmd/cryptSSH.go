@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"io"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// Shares can be encrypted to a shareholder's ssh-ed25519 public key instead
+// of a passphrase, the way age encrypts to SSH recipients: the Ed25519 key is
+// converted to its X25519 equivalent, and the share is sealed with a key
+// derived from an ephemeral X25519 exchange. Only the holder of the private
+// key can decrypt the share, which happens client side with "ssss unwrap".
+const cipherX25519 byte = 2
+
+const x25519Info = "ssss/x25519"
+
+var errUnsupportedKey = errors.New("Only ssh-ed25519 keys can be used to encrypt shares.")
+
+// x25519Recipient converts a public key, encoded like model.User.PublicKey,
+// into an X25519 public key.
+func x25519Recipient(publicKey string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := gossh.ParsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cryptoKey, ok := key.(gossh.CryptoPublicKey)
+	if !ok || key.Type() != gossh.KeyAlgoED25519 {
+		return nil, errUnsupportedKey
+	}
+
+	pub, ok := cryptoKey.CryptoPublicKey().(ed25519.PublicKey)
+	if !ok {
+		return nil, errUnsupportedKey
+	}
+
+	p, err := new(edwards25519.Point).SetBytes(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.BytesMontgomery(), nil
+}
+
+// x25519Identity converts an Ed25519 private key into an X25519 scalar.
+func x25519Identity(key ed25519.PrivateKey) []byte {
+	h := sha512.Sum512(key.Seed())
+	return h[:curve25519.ScalarSize]
+}
+
+func x25519AEAD(shared, ephemeral, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(x25519Info)), key); err != nil {
+		return nil, err
+	}
+
+	return chacha20poly1305.New(key)
+}
+
+// encryptToKey encrypts the plaintext to a public key, encoded like
+// model.User.PublicKey.
+func encryptToKey(plaintext []byte, publicKey string) ([]byte, error) {
+	recipient, err := x25519Recipient(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralSecret := make([]byte, curve25519.ScalarSize)
+	if _, err := io.ReadFull(rand.Reader, ephemeralSecret); err != nil {
+		return nil, err
+	}
+
+	ephemeral, err := curve25519.X25519(ephemeralSecret, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := curve25519.X25519(ephemeralSecret, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := x25519AEAD(shared, ephemeral, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	// The header, ephemeral key and nonce are stored in front of the
+	// encrypted data. The header is also authenticated as additional data.
+	header := append([]byte(cipherMagic), cipherX25519)
+	out := bytes.Join([][]byte{header, ephemeral, nonce}, nil)
+
+	return aead.Seal(out, nonce, plaintext, header), nil
+}
+
+// decryptWithKey decrypts a ciphertext produced by encryptToKey.
+func decryptWithKey(enctext []byte, key ed25519.PrivateKey) ([]byte, error) {
+	if ciphertextVersion(enctext) != cipherX25519 {
+		return nil, errors.New("share is not encrypted to an SSH key")
+	}
+
+	headerSize := len(cipherMagic) + 1
+	if len(enctext) < headerSize+curve25519.PointSize+chacha20poly1305.NonceSize {
+		return nil, errCiphertextTooShort
+	}
+
+	header := enctext[:headerSize]
+	ephemeral := enctext[headerSize : headerSize+curve25519.PointSize]
+	rest := enctext[headerSize+curve25519.PointSize:]
+
+	identity := x25519Identity(key)
+	recipient, err := curve25519.X25519(identity, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := curve25519.X25519(identity, ephemeral)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := x25519AEAD(shared, ephemeral, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, ciphertext := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, header)
+}
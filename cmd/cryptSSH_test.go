@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// testKey generates an ssh-ed25519 key, returning its public half encoded
+// like model.User.PublicKey.
+func testKey(t *testing.T) (string, ed25519.PrivateKey) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return userPublicKey(key), priv
+}
+
+func TestEncryptToKey(t *testing.T) {
+	publicKey, priv := testKey(t)
+	_, other := testKey(t)
+
+	plaintext := []byte("a share")
+	enctext, err := encryptToKey(plaintext, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ciphertextVersion(enctext); v != cipherX25519 {
+		t.Fatalf("version = %d, want %d", v, cipherX25519)
+	}
+
+	decrypted, err := decryptWithKey(enctext, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("plaintext = %q, want %q", decrypted, plaintext)
+	}
+
+	if _, err := decryptWithKey(enctext, other); err == nil {
+		t.Fatal("decrypted with another key")
+	}
+	if _, err := decryptWithKey(enctext[:len(enctext)-20], priv); err == nil {
+		t.Fatal("decrypted a truncated ciphertext")
+	}
+
+	// Passphrase encrypted shares aren't mistaken for ones encrypted to keys
+	passphrased, err := encrypt(plaintext, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decryptWithKey(passphrased, priv); err == nil {
+		t.Fatal("decrypted a passphrase encrypted share")
+	}
+}
+
+func TestEncryptToUnsupportedKey(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := gossh.NewPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := encryptToKey([]byte("a share"), userPublicKey(key)); err != errUnsupportedKey {
+		t.Fatalf("err = %v, want %v", err, errUnsupportedKey)
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	publicKey, priv := testKey(t)
+	otherKey, _ := testKey(t)
+
+	block, err := gossh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(identity, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	mine, err := encryptToKey([]byte("mine"), publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := encryptToKey([]byte("theirs"), otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Shares encrypted to other keys are skipped
+	in := fmt.Sprintf("shares:mine %s\nshares:theirs %s\n",
+		base64.StdEncoding.EncodeToString(mine), base64.StdEncoding.EncodeToString(theirs))
+	out := &bytes.Buffer{}
+
+	cmd := NewUnwrapCmd()
+	cmd.SetArgs([]string{"-i", identity})
+	cmd.SetIn(strings.NewReader(in))
+	cmd.SetOut(out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "shares:mine " + base64.StdEncoding.EncodeToString([]byte("mine")) + "\n"; out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+
+	// Nothing decrypting with the key is an error
+	cmd = NewUnwrapCmd()
+	cmd.SetArgs([]string{"-i", identity})
+	cmd.SetIn(strings.NewReader(fmt.Sprintf("shares:theirs %s\n", base64.StdEncoding.EncodeToString(theirs))))
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("unwrapped a share encrypted to another key")
+	}
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+)
+
+// encryptV0 encrypts the way shares were before ciphertexts had a header.
+func encryptV0(t *testing.T, plaintext []byte, passphrase string) []byte {
+	t.Helper()
+
+	aesGCM, err := newGCM(deriveKeyV0(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, aesGCM.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		t.Fatal(err)
+	}
+
+	return aesGCM.Seal(nonce, nonce, plaintext, nil)
+}
+
+func TestDecryptV0(t *testing.T) {
+	// Sealed with sha256("correct horse") and the nonce 000102...0b
+	enctext, _ := hex.DecodeString("000102030405060708090a0b6ee78f086a3606018f866cdf5f0476d7fe4ba4f929984ef80069e27b1b40bd290e1f66e14781d8e22c3c")
+
+	if v := ciphertextVersion(enctext); v != cipherV0 {
+		t.Fatalf("version = %d, want %d", v, cipherV0)
+	}
+
+	plaintext, err := decrypt(enctext, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a share from before argon2"; string(plaintext) != want {
+		t.Fatalf("plaintext = %q, want %q", plaintext, want)
+	}
+
+	if _, err := decrypt(enctext, "wrong horse"); err == nil {
+		t.Fatal("decrypted with the wrong passphrase")
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	plaintext := []byte("a share")
+	enctext, err := encrypt(plaintext, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := ciphertextVersion(enctext); v != cipherV1 {
+		t.Fatalf("version = %d, want %d", v, cipherV1)
+	}
+
+	decrypted, err := decrypt(enctext, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("plaintext = %q, want %q", decrypted, plaintext)
+	}
+
+	// Salts are random, so the same share never encrypts the same way
+	again, err := encrypt(plaintext, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(again, enctext) {
+		t.Fatal("ciphertexts of the same plaintext are equal")
+	}
+}
+
+func TestDecryptWrongPassphrase(t *testing.T) {
+	enctext, err := encrypt([]byte("a share"), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := decrypt(enctext, "wrong horse"); err == nil {
+		t.Fatal("decrypted with the wrong passphrase")
+	}
+
+	// Tampered ciphertexts fail to open
+	tampered := append([]byte{}, enctext...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := decrypt(tampered, "correct horse"); err == nil {
+		t.Fatal("decrypted a tampered ciphertext")
+	}
+}
+
+func TestDecryptTruncated(t *testing.T) {
+	enctext, err := encrypt([]byte("a share"), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []int{0, 4, len(cipherMagic) + 1, len(cipherMagic) + 1 + saltSize, len(cipherMagic) + 1 + saltSize + 11, len(enctext) - 1} {
+		if _, err := decrypt(enctext[:size], "correct horse"); err == nil {
+			t.Errorf("decrypted %d of %d bytes", size, len(enctext))
+		}
+	}
+
+	if _, err := decryptV0(enctext[:11], "correct horse"); !errors.Is(err, errCiphertextTooShort) {
+		t.Errorf("err = %v, want %v", err, errCiphertextTooShort)
+	}
+	if _, err := decryptV1(enctext[:len(cipherMagic)+1+saltSize+11], "correct horse"); !errors.Is(err, errCiphertextTooShort) {
+		t.Errorf("err = %v, want %v", err, errCiphertextTooShort)
+	}
+}
+
+func TestUnsignRewrapsV0(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, _ := testUser(t, repo, "rewrap-alice")
+	bob, _ := testUser(t, repo, "rewrap-bob")
+
+	secret := testSplit(t, repo, &model.Secret{Parts: 2, Threshold: 2}, []byte("secret"),
+		[]model.User{alice, bob}, []string{"pw-alice", "pw-bob"})
+
+	// Take bob's share back to the format it had before argon2
+	shares, err := repo.Share().MineForSecret(secret.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	share := shares[0]
+	plaintext, err := decrypt(share.Share, "pw-bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	share.Share = encryptV0(t, plaintext, "pw-bob")
+	if err := repo.Share().Update(&share); err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := NewCombineState(repo, secret, alice.ID, secret.Threshold, sharesPerUser(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	if err := unsignShare(repo, cs, bob, []model.Share{share}, "pw-bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	shares, err = repo.Share().MineForSecret(secret.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := ciphertextVersion(shares[0].Share); v != currentCipherVersion {
+		t.Fatalf("version = %d, want %d", v, currentCipherVersion)
+	}
+
+	rewrapped, err := decrypt(shares[0].Share, "pw-bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rewrapped, plaintext) {
+		t.Fatal("re-wrapped share changed")
+	}
+}
@@ -0,0 +1,439 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/ssh"
+)
+
+// The dashboard's tabs.
+const (
+	tabMine = iota
+	tabHeld
+	tabInbox
+)
+
+var dashboardTabs = []string{"My secrets", "Shares I hold", "Inbox"}
+
+// dashboardChrome is the number of lines around the table: the border, the
+// title and tabs, the table's header, the notice and the help.
+const dashboardChrome = 10
+
+type (
+	dashboardTickMsg struct{}
+	dashboardDataMsg struct {
+		mine  []table.Row
+		held  []table.Row
+		inbox []table.Row
+		err   error
+	}
+)
+
+// RunDashboardProgram runs the dashboard until the user quits. Ceremonies are
+// launched by leaving the dashboard and running their own program, after
+// which the dashboard waits for a key press before it comes back, so their
+// outcome stays on screen.
+func RunDashboardProgram(s ssh.Session, repo repository.Repository) error {
+	if s.EmulatedPty() {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		go func(in io.Reader) {
+			io.Copy(w, in)
+			w.Close()
+		}(s)
+		s = pipedSession{Session: s, input: r}
+	}
+
+	paused := false
+	for {
+		launch, err := runDashboard(s, repo, paused)
+		if err != nil || launch == nil {
+			return err
+		}
+
+		// Errors were already printed by the command
+		_ = executeSSHCmd(s, launch)
+		paused = true
+	}
+}
+
+func runDashboard(s ssh.Session, repo repository.Repository, paused bool) ([]string, error) {
+	pty, _, ok := s.Pty()
+	if !ok {
+		return nil, errNoTerminal
+	}
+
+	dashboardTUI := DashboardTUI{
+		TUI:    NewTUI(s),
+		repo:   repo,
+		paused: paused,
+	}
+
+	// Leave u to unsign
+	keys := table.DefaultKeyMap()
+	keys.HalfPageUp = key.NewBinding(key.WithKeys("ctrl+u"))
+	keys.HalfPageDown = key.NewBinding(key.WithKeys("ctrl+d"))
+
+	styles := table.DefaultStyles()
+	styles.Header = dashboardTUI.renderer.NewStyle().Bold(true).Padding(0, 1)
+	styles.Cell = dashboardTUI.renderer.NewStyle().Padding(0, 1)
+	styles.Selected = dashboardTUI.renderer.NewStyle().Bold(true).Foreground(lipgloss.Color("#0F0"))
+
+	dashboardTUI.table = table.New(
+		table.WithColumns(dashboardColumns(tabMine)),
+		table.WithFocused(true),
+		table.WithKeyMap(keys),
+		table.WithStyles(styles),
+		table.WithWidth(pty.Window.Width-4),
+		table.WithHeight(pty.Window.Height-dashboardChrome),
+	)
+
+	opts := []tea.ProgramOption{}
+	if !paused {
+		opts = append(opts, tea.WithAltScreen())
+	}
+
+	m, err := newProgram(s, dashboardTUI, opts...).Run()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.(DashboardTUI).launch, nil
+}
+
+// pipedSession hands the input of an emulated terminal to the dashboard and
+// the programs it launches through a pipe. Programs can only cancel reads of
+// files: reading the session itself, every program that quits leaves a read
+// behind that swallows the next key press.
+type pipedSession struct {
+	ssh.Session
+	input *os.File
+}
+
+func (s pipedSession) Read(p []byte) (int, error) {
+	return s.input.Read(p)
+}
+
+// DashboardTUI browses the user's secrets, the secrets they hold shares of
+// and the ceremonies waiting on them, and launches ceremonies on them.
+type DashboardTUI struct {
+	TUI
+	repo  repository.Repository
+	table table.Model
+
+	tab    int
+	rows   [3][]table.Row
+	notice string
+	err    error
+
+	// paused waits for a key press before showing the dashboard
+	paused bool
+	// launch is the command line to run once the dashboard quits
+	launch []string
+}
+
+func (t DashboardTUI) Init() tea.Cmd {
+	return tea.Batch(
+		t.TUI.Init(),
+		t.load(),
+	)
+}
+
+func (t DashboardTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		t.table.SetWidth(msg.Width - 4)
+		t.table.SetHeight(msg.Height - dashboardChrome)
+	case dashboardTickMsg:
+		return t, t.load()
+	case dashboardDataMsg:
+		t.err = msg.err
+		if msg.err == nil {
+			t.rows = [3][]table.Row{msg.mine, msg.held, msg.inbox}
+			t.table.SetRows(t.rows[t.tab])
+		}
+
+		return t, tea.Tick(time.Second, func(time.Time) tea.Msg {
+			return dashboardTickMsg{}
+		})
+	case tea.KeyMsg:
+		if t.paused {
+			if msg.String() == "q" || msg.String() == "ctrl+c" {
+				return t, tea.Quit
+			}
+
+			t.paused = false
+			return t, tea.EnterAltScreen
+		}
+
+		switch msg.String() {
+		case "tab", "right", "l":
+			t.switchTab((t.tab + 1) % len(dashboardTabs))
+			return t, nil
+		case "shift+tab", "left", "h":
+			t.switchTab((t.tab + len(dashboardTabs) - 1) % len(dashboardTabs))
+			return t, nil
+		case "n":
+			return t.run("split")
+		case "enter":
+			if launch := t.suggest(); launch != nil {
+				return t.run(launch...)
+			}
+
+			t.notice = "Nothing to do for this secret right now."
+			return t, nil
+		case "c", "s", "u":
+			row := t.table.SelectedRow()
+			if row == nil {
+				return t, nil
+			}
+
+			verb := map[string]string{"c": "combine", "s": "sign", "u": "unsign"}[msg.String()]
+			return t.run(verb, row[0])
+		}
+	}
+
+	tui, cmd := t.TUI.Update(msg)
+	if tui, ok := tui.(TUI); ok {
+		t.TUI = tui
+		if cmd != nil {
+			return t, cmd
+		}
+	}
+
+	t.table, cmd = t.table.Update(msg)
+	return t, cmd
+}
+
+func (t *DashboardTUI) switchTab(tab int) {
+	t.tab = tab
+	t.notice = ""
+
+	// Rows must never have more cells than there are columns
+	t.table.SetRows(nil)
+	t.table.SetColumns(dashboardColumns(tab))
+	t.table.SetRows(t.rows[tab])
+	t.table.SetCursor(0)
+}
+
+// run quits the dashboard to run the given command line.
+func (t DashboardTUI) run(args ...string) (tea.Model, tea.Cmd) {
+	t.launch = args
+	return t, tea.Quit
+}
+
+// suggest returns the command line that moves the selected secret along:
+// answering the inbox, combining a ready secret of mine, or joining a
+// ceremony of a secret I hold shares of.
+func (t DashboardTUI) suggest() []string {
+	row := t.table.SelectedRow()
+	if row == nil {
+		return nil
+	}
+	id := row[0]
+
+	switch t.tab {
+	case tabInbox:
+		return []string{row[3], id}
+	case tabMine:
+		if row[3] == "ready" {
+			return []string{"combine", id}
+		}
+	case tabHeld:
+		if _, ok := CombineStates.Get("secrets:" + id); ok {
+			return []string{"unsign", id}
+		}
+		if _, ok := SplitStates.Get("secrets:" + id); ok {
+			return []string{"sign", id}
+		}
+	}
+
+	return nil
+}
+
+// load queries the dashboard's rows.
+func (t DashboardTUI) load() tea.Cmd {
+	repo, user := t.repo, t.user
+
+	return func() tea.Msg {
+		// Every row of a refresh shows the ceremonies as they were at once
+		running := snapshotCeremonies()
+
+		mine, err := repo.Secret().Mine(user.ID)
+		if err != nil {
+			return dashboardDataMsg{err: err}
+		}
+		held, err := repo.Secret().Held(user.ID)
+		if err != nil {
+			return dashboardDataMsg{err: err}
+		}
+		waiting, err := inbox(repo, user, running)
+		if err != nil {
+			return dashboardDataMsg{err: err}
+		}
+
+		msg := dashboardDataMsg{}
+		sortSecrets(mine)
+		for _, secret := range mine {
+			if secret.Archived {
+				continue
+			}
+
+			msg.mine = append(msg.mine, table.Row{
+				shortID(secret.ID),
+				secret.Label,
+				fmt.Sprintf("%d/%d", secret.Threshold, secret.Parts),
+				ceremonyStatus(&secret, running),
+				secret.CreatedAt.Format(timeFormat),
+			})
+		}
+
+		sortSecrets(held)
+		for _, secret := range held {
+			shares, err := repo.Share().MineForSecret(secret.ID, user.ID)
+			if err != nil {
+				return dashboardDataMsg{err: err}
+			}
+			creator, err := repo.User().Get(secret.User)
+			if err != nil {
+				return dashboardDataMsg{err: err}
+			}
+
+			msg.held = append(msg.held, table.Row{
+				shortID(secret.ID),
+				secret.Label,
+				fmt.Sprintf("%d/%d", secret.Threshold, secret.Parts),
+				ceremonyStatus(&secret, running),
+				fmt.Sprint(len(shares)),
+				creator.Username,
+			})
+		}
+
+		for _, o := range waiting {
+			msg.inbox = append(msg.inbox, table.Row{
+				o.ID,
+				o.Label,
+				o.Ceremony,
+				o.Action,
+				fmt.Sprint(o.Remaining),
+				o.Initiator,
+				o.ExpiresAt.Format(timeFormat),
+			})
+		}
+
+		return msg
+	}
+}
+
+func dashboardColumns(tab int) []table.Column {
+	switch tab {
+	case tabHeld:
+		return []table.Column{
+			{Title: "ID", Width: 20},
+			{Title: "LABEL", Width: 20},
+			{Title: "THRESHOLD", Width: 9},
+			{Title: "STATUS", Width: 28},
+			{Title: "SHARES", Width: 6},
+			{Title: "CREATOR", Width: 12},
+		}
+	case tabInbox:
+		return []table.Column{
+			{Title: "ID", Width: 20},
+			{Title: "LABEL", Width: 20},
+			{Title: "CEREMONY", Width: 8},
+			{Title: "ACTION", Width: 6},
+			{Title: "REMAINING", Width: 9},
+			{Title: "INITIATOR", Width: 12},
+			{Title: "EXPIRES", Width: 19},
+		}
+	}
+
+	return []table.Column{
+		{Title: "ID", Width: 20},
+		{Title: "LABEL", Width: 20},
+		{Title: "THRESHOLD", Width: 9},
+		{Title: "STATUS", Width: 28},
+		{Title: "CREATED", Width: 19},
+	}
+}
+
+// ceremonyStatus is the status of a secret, along with the progress of its
+// running ceremony.
+func ceremonyStatus(secret *model.Secret, running runningCeremonies) string {
+	if rs, ok := running.reshares[secret.ID]; ok {
+		return fmt.Sprintf("%s %d/%d signed, %d/%d unsigned", secret.Status, rs.Split.Len(), rs.Split.Expected, rs.Combine.Len(), rs.Combine.Expected)
+	}
+	if ss, ok := running.splits[secret.ID]; ok {
+		return fmt.Sprintf("%s %d/%d", secret.Status, ss.Len(), ss.Expected)
+	}
+	if cs, ok := running.combines[secret.ID]; ok {
+		return fmt.Sprintf("combining %d/%d", cs.Len(), cs.Expected)
+	}
+
+	return secret.Status
+}
+
+func sortSecrets(secrets []model.Secret) {
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].CreatedAt.Before(secrets[j].CreatedAt)
+	})
+}
+
+func (t DashboardTUI) View() string {
+	if t.launch != nil {
+		return ""
+	}
+	if t.paused {
+		return "Press any key to return to the dashboard, or q to quit.\n"
+	}
+
+	v := NewView()
+	v.Colorf(lipgloss.Color("#0F0"), "Shamir's Secret Sharing Service")
+	v.WriteString(" — signed in as " + t.user.Username)
+	v.NL()
+	v.NL()
+
+	for i, title := range dashboardTabs {
+		if i > 0 {
+			v.WriteString("   ")
+		}
+
+		title = fmt.Sprintf("%s (%d)", title, len(t.rows[i]))
+		if i == t.tab {
+			v.Colorf(lipgloss.Color("#0F0"), "[%s]", title)
+		} else {
+			v.WriteString(" " + title + " ")
+		}
+	}
+	v.NL()
+	v.NL()
+
+	v.WriteString(t.table.View())
+	v.NL()
+
+	switch {
+	case t.err != nil:
+		v.Colorf(lipgloss.Color("#F00"), "%s", t.err.Error())
+	case t.notice != "":
+		v.Colorf(lipgloss.Color("#FF0"), "%s", t.notice)
+	}
+	v.NL()
+
+	v.Colorf(lipgloss.Color("#888"), "tab switch · ↑/↓ select · enter act · n split · c combine · s sign · u unsign · q quit")
+
+	return t.renderer.NewStyle().Width(t.width-2).Border(lipgloss.RoundedBorder(), true).Render(v.String()) + "\n"
+}
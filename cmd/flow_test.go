@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/adamgoose/ssss/lib/repository/memory"
+	"github.com/defval/di"
+	"github.com/spf13/viper"
+)
+
+func TestMain(m *testing.M) {
+	viper.Set("split_timeout", time.Hour)
+	viper.Set("combine_timeout", time.Hour)
+	viper.Set("reshare_timeout", time.Hour)
+	viper.Set("unsign_attempts", 5)
+	viper.Set("unsign_lockout", time.Minute)
+	viper.Set("registration", registrationOpen)
+
+	if err := lib.Apply(
+		di.Provide(memory.NewStore),
+		di.ProvideValue(memory.MemoryRepository{}, di.As(new(repository.Repository))),
+	); err != nil {
+		panic(err)
+	}
+
+	os.Exit(m.Run())
+}
+
+// testUser registers a user with a new ed25519 key, and returns them with
+// the key's private half.
+func testUser(t *testing.T, repo repository.Repository, username string) (model.User, ed25519.PrivateKey) {
+	t.Helper()
+
+	publicKey, priv := testKey(t)
+	user, err := repo.User().Upsert(&model.User{
+		Username:  username,
+		PublicKey: publicKey,
+		Status:    userActive,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return *user, priv
+}
+
+func passphraseOf(user model.User, passphrase string) Passphrase {
+	return Passphrase{
+		UserID:     user.ID,
+		Username:   user.Username,
+		PublicKey:  user.PublicKey,
+		Keys:       user.Verified,
+		Passphrase: passphrase,
+	}
+}
+
+// testSplit splits the plaintext, with each signer signing one share with
+// their passphrase. The first signer creates the secret.
+func testSplit(t *testing.T, repo repository.Repository, secret *model.Secret, plaintext []byte, signers []model.User, passphrases []string) *model.Secret {
+	t.Helper()
+
+	secret, ss, err := startSplit(repo, signers[0], secret, passphrases[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, signer := range signers[1:] {
+		if err := ss.Push(passphraseOf(signer, passphrases[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for ss.Len() < ss.Expected {
+		if _, err := ss.ReceiveOne(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := finishSplit(repo, secret, ss, plaintext); err != nil {
+		t.Fatal(err)
+	}
+
+	return secret
+}
+
+// testUnsign unsigns one share of each holder with their passphrase.
+func testUnsign(t *testing.T, repo repository.Repository, cs *CombineState, holders []model.User, passphrases []string) {
+	t.Helper()
+
+	for i, holder := range holders {
+		shares, err := repo.Share().MineForSecret(cs.SecretID, holder.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := unsignShare(repo, cs, holder, shares, passphrases[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for cs.Len() < len(holders) {
+		if _, err := cs.ReceiveOne(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, _ := testUser(t, repo, "flow-alice")
+	bob, _ := testUser(t, repo, "flow-bob")
+	carol, _ := testUser(t, repo, "flow-carol")
+
+	plaintext := []byte("correct horse battery staple")
+	secret := testSplit(t, repo, &model.Secret{Parts: 3, Threshold: 2}, plaintext,
+		[]model.User{alice, bob, carol}, []string{"pw-alice", "pw-bob", "pw-carol"})
+
+	if secret.Status != "ready" {
+		t.Fatalf("status = %q, want ready", secret.Status)
+	}
+	if _, ok := SplitStates.Get(secret.ID); ok {
+		t.Fatal("split ceremony still registered")
+	}
+
+	shares, err := repo.Share().ForSecret(secret.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 3 {
+		t.Fatalf("got %d shares, want 3", len(shares))
+	}
+
+	cs, err := NewCombineState(repo, secret, alice.ID, secret.Threshold, sharesPerUser(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	testUnsign(t, repo, cs, []model.User{bob, carol}, []string{"pw-bob", "pw-carol"})
+
+	recovered, err := recoverSecret(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered, plaintext) {
+		t.Fatalf("recovered %q, want %q", recovered, plaintext)
+	}
+}
+
+func TestUnsign(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, _ := testUser(t, repo, "unsign-alice")
+	bob, _ := testUser(t, repo, "unsign-bob")
+
+	secret := testSplit(t, repo, &model.Secret{Parts: 2, Threshold: 2}, []byte("secret"),
+		[]model.User{alice, bob}, []string{"pw-alice", "pw-bob"})
+
+	cs, err := NewCombineState(repo, secret, alice.ID, secret.Threshold, sharesPerUser(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	shares, err := repo.Share().MineForSecret(secret.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wrong passphrases are refused, until too many of them lock bob out
+	for i := 0; i < viper.GetInt("unsign_attempts"); i++ {
+		if err := unsignShare(repo, cs, bob, shares, "wrong"); !errors.Is(err, errNoShare) {
+			t.Fatalf("attempt %d: err = %v, want %v", i, err, errNoShare)
+		}
+	}
+	if err := unsignShare(repo, cs, bob, shares, "pw-bob"); !errors.Is(err, errTooManyAttempts) {
+		t.Fatalf("err = %v, want %v", err, errTooManyAttempts)
+	}
+	unsignAttempts.Reset(bob.ID + "/" + secret.ID)
+
+	if err := unsignShare(repo, cs, bob, shares, "pw-bob"); err != nil {
+		t.Fatal(err)
+	}
+	if !cs.Included(shares[0].Key) {
+		t.Fatal("unsigned share not included")
+	}
+
+	// Each holder unsigns one share per combine
+	if err := unsignShare(repo, cs, bob, shares, "pw-bob"); !errors.Is(err, errAlreadyUnsigned) {
+		t.Fatalf("err = %v, want %v", err, errAlreadyUnsigned)
+	}
+}
+
+func TestSplitExpires(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, _ := testUser(t, repo, "expire-alice")
+
+	viper.Set("split_timeout", 50*time.Millisecond)
+	defer viper.Set("split_timeout", time.Hour)
+
+	secret, ss, err := startSplit(repo, alice, &model.Secret{Parts: 2, Threshold: 2}, "pw-alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ss.ReceiveOne(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ss.ReceiveOne(); !errors.Is(err, errCeremonyExpired) {
+		t.Fatalf("err = %v, want %v", err, errCeremonyExpired)
+	}
+
+	if err := expireSplit(repo, secret, ss); err != nil {
+		t.Fatal(err)
+	}
+	if secret.Status != "expired" {
+		t.Fatalf("status = %q, want expired", secret.Status)
+	}
+	if _, ok := SplitStates.Get(secret.ID); ok {
+		t.Fatal("split ceremony still registered")
+	}
+	if err := ss.Push(passphraseOf(alice, "pw-alice")); !errors.Is(err, errCeremonyClosed) {
+		t.Fatalf("err = %v, want %v", err, errCeremonyClosed)
+	}
+}
+
+func TestExpireCeremonies(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, _ := testUser(t, repo, "orphan-alice")
+
+	// A split orphaned by a previous run of the server
+	secret, err := repo.Secret().Create(&model.Secret{User: alice.ID, Parts: 2, Threshold: 2, Status: "signing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := NewSplitState(repo, secret.ID, alice.ID, secret.Parts, sharesPerUser(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SplitStates.Delete(secret.ID, ss)
+
+	if err := ExpireCeremonies(repo); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Ceremony().ForSecret(secret.ID); err == nil {
+		t.Fatal("orphaned ceremony still stored")
+	}
+	expired, err := repo.Secret().Get(secret.ID[len("secrets:"):])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired.Status != "dead" {
+		t.Fatalf("status = %q, want dead", expired.Status)
+	}
+}
+
+func TestExpireCeremoniesRepairsRefresh(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, _ := testUser(t, repo, "repair-alice")
+	bob, _ := testUser(t, repo, "repair-bob")
+
+	secret := testSplit(t, repo, &model.Secret{Parts: 2, Threshold: 2}, []byte("secret"),
+		[]model.User{alice, bob}, []string{"pw-alice", "pw-bob"})
+
+	// A refresh that stored its shares and pointed the secret at them, but
+	// stopped before revoking the previous ones
+	for _, user := range []model.User{alice, bob} {
+		if _, err := repo.Share().Create(&model.Share{Secret: secret.ID, User: user.ID, Generation: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	secret.Generation = 1
+	secret.Status = "refreshing"
+	if err := repo.Secret().Update(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ExpireCeremonies(repo); err != nil {
+		t.Fatal(err)
+	}
+
+	shares, err := repo.Share().ForSecret(secret.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 2 {
+		t.Fatalf("got %d shares, want 2", len(shares))
+	}
+	for _, share := range shares {
+		if share.Generation != 1 {
+			t.Fatalf("share of generation %d left current", share.Generation)
+		}
+	}
+
+	repaired, err := repo.Secret().Get(shortID(secret.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repaired.Status != "ready" {
+		t.Fatalf("status = %q, want ready", repaired.Status)
+	}
+}
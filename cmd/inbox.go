@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"sort"
+	"time"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+)
+
+// InboxOutput is the machine-readable representation of a ceremony waiting
+// on the user.
+type InboxOutput struct {
+	ID        string    `json:"id" yaml:"id"`
+	Label     string    `json:"label" yaml:"label"`
+	Ceremony  string    `json:"ceremony" yaml:"ceremony"`
+	Action    string    `json:"action" yaml:"action"`
+	Remaining int       `json:"remaining" yaml:"remaining"`
+	Initiator string    `json:"initiator" yaml:"initiator"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+	Command   string    `json:"command" yaml:"command"`
+}
+
+// inbox lists the running ceremonies waiting on the user: splits, reshares
+// and refreshes that name them as a shareholder they have yet to sign, and
+// combines of secrets they hold shares of that they have yet to unsign.
+// Splits open to anyone aren't listed, nobody in particular is expected to
+// sign them.
+func inbox(repo repository.Repository, user model.User, running runningCeremonies) ([]InboxOutput, error) {
+	outputs := []InboxOutput{}
+
+	for id, ss := range running.splits {
+		secret, err := repo.Secret().Get(shortID(id))
+		if err != nil {
+			return nil, err
+		}
+
+		target := secret
+		rs, resharing := running.reshares[id]
+		if resharing {
+			target = rs.Target
+		}
+
+		remaining := 0
+		switch {
+		case resharing && rs.Refresh:
+			shares, err := repo.Share().MineForSecret(id, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			remaining = len(shares) - ss.SignedBy(user.ID)
+		default:
+			for _, pending := range ss.Pending(target.Shareholders) {
+				if designates(target, pending, user) {
+					remaining = 1
+				}
+			}
+		}
+		if remaining <= 0 {
+			continue
+		}
+
+		o, err := newInboxOutput(repo, secret, ss.ceremony, rs, actionSign, remaining)
+		if err != nil {
+			return nil, err
+		}
+		o.Command = signCommand(secret, false)
+		outputs = append(outputs, o)
+	}
+
+	for id, cs := range running.combines {
+		if cs.Len() >= cs.Expected {
+			continue
+		}
+
+		shares, err := repo.Share().MineForSecret(id, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		remaining := len(shares)
+		if cs.PerUser > 0 {
+			remaining = min(remaining, cs.PerUser)
+		}
+		remaining -= cs.UnsignedBy(user.ID)
+		if remaining <= 0 {
+			continue
+		}
+
+		o, err := newInboxOutput(repo, cs.secret, cs.ceremony, running.reshares[id], actionUnsign, remaining)
+		if err != nil {
+			return nil, err
+		}
+		o.Command = unsignCommand(cs.secret, false)
+		outputs = append(outputs, o)
+	}
+
+	sort.Slice(outputs, func(i, j int) bool {
+		return outputs[i].ExpiresAt.Before(outputs[j].ExpiresAt)
+	})
+
+	return outputs, nil
+}
+
+func newInboxOutput(repo repository.Repository, secret *model.Secret, ceremony *model.Ceremony, rs *ReshareState, action string, remaining int) (InboxOutput, error) {
+	initiator, err := repo.User().Get(ceremony.User)
+	if err != nil {
+		return InboxOutput{}, err
+	}
+
+	// Reshares and refreshes run a split and a combine under one name
+	kind := ceremony.Kind
+	if rs != nil {
+		kind = "reshare"
+		if rs.Refresh {
+			kind = "refresh"
+		}
+	}
+
+	return InboxOutput{
+		ID:        shortID(secret.ID),
+		Label:     secret.Label,
+		Ceremony:  kind,
+		Action:    action,
+		Remaining: remaining,
+		Initiator: initiator.Username,
+		ExpiresAt: ceremony.ExpiresAt,
+	}, nil
+}
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// KeyOutput is the machine-readable representation of one of the user's
+// keys.
+type KeyOutput struct {
+	Fingerprint string `json:"fingerprint" yaml:"fingerprint"`
+	Type        string `json:"type" yaml:"type"`
+	Current     bool   `json:"current" yaml:"current"`
+}
+
+// userKeys lists the user's keys. Users registered before they could own
+// several keys only have their PublicKey.
+func userKeys(user model.User) []string {
+	if len(user.Keys) == 0 {
+		return []string{user.PublicKey}
+	}
+
+	return user.Keys
+}
+
+// verifiedKeys lists the keys the user authenticated with. Users registered
+// before they could have several keys only have their PublicKey.
+func verifiedKeys(user model.User) []string {
+	if len(user.Verified) == 0 {
+		return []string{user.PublicKey}
+	}
+
+	return user.Verified
+}
+
+// newKeysCmd builds the commands managing the keys a user connects with.
+// Every key identifies the same user, so their shares follow them to a new
+// key.
+func newKeysCmd(sess ssh.Session) *cobra.Command {
+	keysCmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manages the SSH keys you connect with.",
+	}
+
+	lsCmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "Lists your keys.",
+		Args:    cobra.NoArgs,
+		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			out := cmd.OutOrStdout()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			session := sessionUser(sess)
+			user, err := repo.User().Get(session.ID)
+			if err != nil {
+				return err
+			}
+
+			outputs := []KeyOutput{}
+			for _, key := range userKeys(*user) {
+				o := KeyOutput{
+					Fingerprint: displayShareholder(key),
+					Current:     key == session.PublicKey,
+				}
+				if pk, err := parseUserKey(key); err == nil {
+					o.Type = pk.Type()
+				}
+				outputs = append(outputs, o)
+			}
+
+			if format != "table" {
+				return writeOutput(out, format, outputs)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "FINGERPRINT\tTYPE\tCURRENT")
+			for _, o := range outputs {
+				current := ""
+				if o.Current {
+					current = "*"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", o.Fingerprint, o.Type, current)
+			}
+
+			return tw.Flush()
+		}),
+	}
+
+	addCmd := &cobra.Command{
+		Use:   "add [key]",
+		Short: "Adds a key in authorized_keys format, read from stdin when not given, once you claim it connected with it.",
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			line := strings.Join(args, " ")
+			if len(args) == 0 {
+				data, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				line = string(data)
+			}
+
+			key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(line)))
+			if err != nil {
+				return errors.New("Expected a public key in authorized_keys format.")
+			}
+
+			if !keyTypeAllowed(key) {
+				return fmt.Errorf("Keys of type %s aren't accepted.", key.Type())
+			}
+
+			// Admins are let in by their keys alone, which no account owns
+			publicKey := userPublicKey(key)
+			if isAdmin(publicKey) {
+				return errors.New("Admin keys can't be added to an account, connect with them directly.")
+			}
+
+			session := sessionUser(sess)
+			switch owner, err := repo.User().ForKey(publicKey); {
+			case err == nil && owner.ID == session.ID:
+				return errors.New("This key is already one of yours.")
+			case err == nil:
+				return errKeyTaken
+			case !errors.Is(err, repository.ErrNotFound):
+				return err
+			}
+
+			// The key only joins the user once a session authenticated with
+			// it claims it
+			token, err := keyClaims.Add(session.ID, session.Username, publicKey)
+			if err != nil {
+				return err
+			}
+
+			log.Info("Opened a key claim", "user.id", session.ID, "fingerprint", gossh.FingerprintSHA256(key))
+			fmt.Fprintf(cmd.ErrOrStderr(), "Claim key %s within %s by connecting with it:\n", gossh.FingerprintSHA256(key), viper.GetDuration("key_claim_timeout"))
+			fmt.Fprintf(cmd.OutOrStdout(), "ssh %s@enge.me -- keys claim %s\n", session.Username, token)
+			return nil
+		}),
+	}
+
+	claimCmd := &cobra.Command{
+		Use:   "claim {token}",
+		Short: "Claims the key you are connected with, added with \"keys add\".",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string) error {
+			// The claim was redeemed as the session authenticated
+			session := sessionUser(sess)
+			if !keyClaims.Redeemed(args[0], session.PublicKey) {
+				return errors.New("Unknown or expired claim, add the key again with \"keys add\" and connect as the same user.")
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Added key %s.\n", displayShareholder(session.PublicKey))
+			return nil
+		}),
+	}
+
+	rmCmd := &cobra.Command{
+		Use:     "remove {fingerprint}",
+		Aliases: []string{"rm"},
+		Short:   "Removes a key, other than the one you are connected with.",
+		Args:    cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			session := sessionUser(sess)
+			user, err := repo.User().Get(session.ID)
+			if err != nil {
+				return err
+			}
+
+			removed := ""
+			keys := []string{}
+			for _, key := range userKeys(*user) {
+				if displayShareholder(key) == args[0] {
+					removed = key
+					continue
+				}
+				keys = append(keys, key)
+			}
+
+			switch removed {
+			case "":
+				return fmt.Errorf("You don't have a key with the fingerprint %s.", args[0])
+			case session.PublicKey:
+				return errors.New("You can't remove the key you are connected with, connect with another one of your keys.")
+			}
+
+			verified := []string{}
+			for _, key := range user.Verified {
+				if key != removed {
+					verified = append(verified, key)
+				}
+			}
+
+			// The key the user registered with may go too
+			user.Keys = keys
+			user.Verified = verified
+			if user.PublicKey == removed {
+				user.PublicKey = verifiedKeys(*user)[0]
+			}
+			if err := repo.User().Update(user); err != nil {
+				return err
+			}
+
+			log.Info("Removed a key", "user.id", user.ID, "fingerprint", args[0])
+			fmt.Fprintf(cmd.ErrOrStderr(), "Removed key %s.\n", args[0])
+			return nil
+		}),
+	}
+
+	keysCmd.AddCommand(lsCmd)
+	keysCmd.AddCommand(addCmd)
+	keysCmd.AddCommand(claimCmd)
+	keysCmd.AddCommand(rmCmd)
+
+	return keysCmd
+}
+
+// parseUserKey decodes a key encoded the way model.User.PublicKey is.
+func parseUserKey(publicKey string) (ssh.PublicKey, error) {
+	data, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePublicKey(data)
+}
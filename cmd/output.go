@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+// SecretOutput is the machine-readable representation of a secret.
+type SecretOutput struct {
+	ID             string    `json:"id" yaml:"id"`
+	Label          string    `json:"label" yaml:"label"`
+	Parts          int       `json:"parts" yaml:"parts"`
+	Threshold      int       `json:"threshold" yaml:"threshold"`
+	Status         string    `json:"status" yaml:"status"`
+	Encryption     string    `json:"encryption" yaml:"encryption"`
+	Archived       bool      `json:"archived" yaml:"archived"`
+	CreatedAt      time.Time `json:"created_at" yaml:"created_at"`
+	Shareholders   []string  `json:"shareholders" yaml:"shareholders"`
+	Recipients     []string  `json:"recipients" yaml:"recipients"`
+	MultipleShares bool      `json:"multiple_shares" yaml:"multiple_shares"`
+
+	// Secret is only set once a combine ceremony recovered the secret.
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+}
+
+// shortID strips the table from a record ID, leaving the ID users type.
+func shortID(id string) string {
+	if _, short, ok := strings.Cut(id, ":"); ok {
+		return short
+	}
+
+	return id
+}
+
+// signCommand is the command shareholders run to sign a share of the secret.
+func signCommand(secret *model.Secret, stdin bool) string {
+	switch {
+	case secret.Encryption == "ssh":
+		return fmt.Sprintf("ssh enge.me -- sign %s", shortID(secret.ID))
+	case stdin:
+		return fmt.Sprintf("ssh enge.me -- sign --stdin %s", shortID(secret.ID))
+	}
+
+	return fmt.Sprintf("ssh -t enge.me -- sign %s", shortID(secret.ID))
+}
+
+// unsignCommand is the command shareholders run to unsign their share of the
+// secret. Shares encrypted to SSH keys are decrypted client side by "ssss
+// unwrap", so the private key never leaves the shareholder's machine.
+func unsignCommand(secret *model.Secret, stdin bool) string {
+	switch {
+	case secret.Encryption == "ssh":
+		return fmt.Sprintf("ssh enge.me -- unsign --export %[1]s | ssss unwrap | ssh enge.me -- unsign --stdin %[1]s", shortID(secret.ID))
+	case stdin:
+		return fmt.Sprintf("ssh enge.me -- unsign --stdin %s", shortID(secret.ID))
+	}
+
+	return fmt.Sprintf("ssh -t enge.me -- unsign %s", shortID(secret.ID))
+}
+
+func newSecretOutput(repo repository.Repository, secret *model.Secret) (SecretOutput, error) {
+	out := SecretOutput{
+		ID:             shortID(secret.ID),
+		Label:          secret.Label,
+		Parts:          secret.Parts,
+		Threshold:      secret.Threshold,
+		Status:         secret.Status,
+		Encryption:     secret.Encryption,
+		Archived:       secret.Archived,
+		CreatedAt:      secret.CreatedAt,
+		Shareholders:   []string{},
+		Recipients:     []string{},
+		MultipleShares: secret.MultipleShares,
+	}
+	recipients := []string{}
+	for _, id := range secret.Recipients {
+		recipients = appendUnique(recipients, id)
+	}
+	names := shareholderNames(repo, recipients)
+	for _, id := range recipients {
+		out.Recipients = append(out.Recipients, names[id])
+	}
+	if out.Encryption == "" {
+		out.Encryption = "passphrase"
+	}
+
+	holders, err := shareHolders(repo, secret)
+	if err != nil {
+		return out, err
+	}
+
+	for _, username := range holders {
+		out.Shareholders = append(out.Shareholders, username)
+	}
+	sort.Strings(out.Shareholders)
+
+	return out, nil
+}
+
+// shareHolders maps the IDs of the users holding current shares of the
+// secret to their usernames.
+func shareHolders(repo repository.Repository, secret *model.Secret) (map[string]string, error) {
+	shares, err := repo.Share().ForSecret(secret.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	holders := map[string]string{}
+	for _, share := range shares {
+		if _, ok := holders[share.User]; ok {
+			continue
+		}
+
+		user, err := repo.User().Get(share.User)
+		if err != nil {
+			return nil, err
+		}
+		holders[share.User] = user.Username
+	}
+
+	return holders, nil
+}
+
+// outputFormat returns the validated --output flag of the command.
+func outputFormat(cmd *cobra.Command) (string, error) {
+	format, _ := cmd.Flags().GetString("output")
+	switch format {
+	case "table", "json", "yaml":
+		return format, nil
+	}
+
+	return "", fmt.Errorf("Unknown output format %q.", format)
+}
+
+// writeOutput encodes v as a single json or yaml document.
+func writeOutput(w io.Writer, format string, v any) error {
+	switch format {
+	case "json":
+		return json.NewEncoder(w).Encode(v)
+	case "yaml":
+		// Documents are written one at a time, so separate each explicitly
+		if _, err := io.WriteString(w, "---\n"); err != nil {
+			return err
+		}
+
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	return fmt.Errorf("Unknown output format %q.", format)
+}
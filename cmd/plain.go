@@ -0,0 +1,450 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/spf13/cobra"
+)
+
+// The plain programs are the non-interactive counterparts of the TUIs. They
+// read secrets and passphrases from stdin, report progress on stderr and
+// print results on stdout, so ceremonies can be scripted over ssh exec.
+
+// readLine reads a single line, without its line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readPassphrase reads a non-empty passphrase from the first line of r.
+func readPassphrase(r *bufio.Reader) (string, error) {
+	passphrase, err := readLine(r)
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase == "" {
+		return "", errors.New("Expected a passphrase on stdin.")
+	}
+
+	return passphrase, nil
+}
+
+// watchSession closes the ceremony once the session ends, the way q and
+// ctrl+c cancel it in the TUIs. The returned func stops watching, and
+// reports whether the session ended first.
+func watchSession(s ssh.Session, ceremony interface{ Close() error }) func() bool {
+	stop, done := make(chan struct{}), make(chan struct{})
+	ended := false
+	go func() {
+		defer close(done)
+		select {
+		case <-s.Context().Done():
+			ended = true
+			ceremony.Close()
+		case <-stop:
+		}
+	}()
+
+	var once sync.Once
+	return func() bool {
+		once.Do(func() { close(stop) })
+		<-done
+		return ended
+	}
+}
+
+// writeSecret prints the secret as a json or yaml document.
+func writeSecret(w io.Writer, format string, repo repository.Repository, secret *model.Secret) error {
+	o, err := newSecretOutput(repo, secret)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(w, format, o)
+}
+
+// RunSplitPlain reads the passphrase from the first line of stdin and the
+// secret from the rest of it.
+func RunSplitPlain(s ssh.Session, repo repository.Repository, cmd *cobra.Command) error {
+	user := sessionUser(s)
+	out, errOut := cmd.OutOrStdout(), cmd.ErrOrStderr()
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	label, _ := cmd.Flags().GetString("label")
+	parts, _ := cmd.Flags().GetInt("parts")
+	threshold, _ := cmd.Flags().GetInt("threshold")
+	shareholders, _ := cmd.Flags().GetStringSlice("shareholders")
+	recipients, _ := cmd.Flags().GetStringSlice("recipients")
+	encryption, _ := cmd.Flags().GetString("encryption")
+	multipleShares, _ := cmd.Flags().GetBool("multiple-shares")
+
+	// Shares encrypted to SSH keys don't need a passphrase
+	in := bufio.NewReader(cmd.InOrStdin())
+	passphrase := ""
+	if encryption != "ssh" {
+		if passphrase, err = readPassphrase(in); err != nil {
+			return err
+		}
+	}
+
+	plaintext, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	plaintext = []byte(strings.TrimSuffix(strings.TrimSuffix(string(plaintext), "\n"), "\r"))
+	if len(plaintext) == 0 {
+		return errors.New("Expected a secret on stdin.")
+	}
+
+	shareholders, err = normalizeShareholders(repo, shareholders)
+	if err != nil {
+		return err
+	}
+	recipients, err = normalizeShareholders(repo, recipients)
+	if err != nil {
+		return err
+	}
+
+	secret, ss, err := startSplit(repo, user, &model.Secret{
+		Label:          label,
+		Parts:          parts,
+		Threshold:      threshold,
+		Shareholders:   shareholders,
+		Recipients:     recipients,
+		Encryption:     encryption,
+		MultipleShares: multipleShares,
+	}, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if format == "table" {
+		fmt.Fprintln(out, shortID(secret.ID))
+	} else if err := writeSecret(out, format, repo, secret); err != nil {
+		return err
+	}
+	fmt.Fprintf(errOut, "Ask others to sign their shares with: %s\n", signCommand(secret, true))
+
+	ended := watchSession(s, ss)
+	defer ended()
+
+	for ss.Len() < ss.Expected {
+		p, err := ss.ReceiveOne()
+		if errors.Is(err, errCeremonyExpired) {
+			expireSplit(repo, secret, ss)
+			return err
+		} else if errors.Is(err, errCeremonyClosed) {
+			if ended() {
+				abortSplit(repo, secret, ss)
+			}
+			return err
+		} else if err != nil {
+			log.Error("Unable to persist ceremony", "id", secret.ID, "error", err)
+		}
+
+		fmt.Fprintf(errOut, "Signed by %s (%d/%d)\n", p.Username, ss.Len(), ss.Expected)
+	}
+
+	if ended() {
+		abortSplit(repo, secret, ss)
+		return errCeremonyClosed
+	}
+
+	if err := finishSplit(repo, secret, ss, plaintext); err != nil {
+		failSplit(repo, secret, ss, err)
+		return err
+	}
+
+	if format != "table" {
+		return writeSecret(out, format, repo, secret)
+	}
+
+	fmt.Fprintf(errOut, "Retrieve your secret with: ssh enge.me -- combine --stdin %s\n", shortID(secret.ID))
+	return nil
+}
+
+// RunSignPlain reads the passphrase from the first line of stdin.
+func RunSignPlain(s ssh.Session, repo repository.Repository, cmd *cobra.Command, secret *model.Secret, ss *SplitState) error {
+	user := sessionUser(s)
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase(bufio.NewReader(cmd.InOrStdin()))
+	if err != nil {
+		return err
+	}
+
+	if err := ss.Push(Passphrase{
+		UserID:     user.ID,
+		Username:   user.Username,
+		PublicKey:  user.PublicKey,
+		Keys:       user.Verified,
+		Passphrase: passphrase,
+	}); err != nil {
+		return err
+	}
+
+	if format != "table" {
+		return writeSecret(cmd.OutOrStdout(), format, repo, secret)
+	}
+
+	fmt.Fprintln(cmd.ErrOrStderr(), "You signed the secret!")
+	return nil
+}
+
+// RunCombinePlain waits for the shareholders and prints the secret.
+func RunCombinePlain(s ssh.Session, repo repository.Repository, cmd *cobra.Command, secret *model.Secret, cs *CombineState) error {
+	out, errOut := cmd.OutOrStdout(), cmd.ErrOrStderr()
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(errOut, "Ask others to unsign their shares with: %s\n", unsignCommand(secret, true))
+
+	ended := watchSession(s, cs)
+	defer ended()
+
+	for cs.Len() < cs.Expected {
+		var rejected *shareRejectedError
+		p, err := cs.ReceiveOne()
+		if errors.Is(err, errCeremonyExpired) {
+			expireCombine(cs)
+			return err
+		} else if errors.Is(err, errCeremonyClosed) {
+			if ended() {
+				abortCombine(cs)
+			}
+			return err
+		} else if errors.As(err, &rejected) {
+			fmt.Fprintf(errOut, "Rejected a share from %s that failed verification\n", rejected.Username)
+			continue
+		} else if err != nil {
+			log.Error("Unable to persist ceremony", "id", cs.SecretID, "error", err)
+		}
+
+		fmt.Fprintf(errOut, "Unsigned by %s (%d/%d)\n", p.Username, cs.Len(), cs.Expected)
+	}
+
+	if ended() {
+		abortCombine(cs)
+		return errCeremonyClosed
+	}
+
+	plaintext, err := combineShares(cs)
+	if err != nil {
+		return err
+	}
+	if len(secret.Digest) > 0 {
+		fmt.Fprintln(errOut, "Recovered and verified the secret")
+	}
+
+	if format == "table" {
+		fmt.Fprintln(out, string(plaintext))
+		return nil
+	}
+
+	o, err := newSecretOutput(repo, secret)
+	if err != nil {
+		return err
+	}
+	o.Secret = string(plaintext)
+
+	return writeOutput(out, format, o)
+}
+
+// RunUnsignPlain reads the passphrase from the first line of stdin.
+func RunUnsignPlain(s ssh.Session, repo repository.Repository, cmd *cobra.Command, secret *model.Secret, cs *CombineState, shares []model.Share) error {
+	user := sessionUser(s)
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := readPassphrase(bufio.NewReader(cmd.InOrStdin()))
+	if err != nil {
+		return err
+	}
+
+	if err := unsignShare(repo, cs, user, shares, passphrase); err != nil {
+		return err
+	}
+
+	if format != "table" {
+		return writeSecret(cmd.OutOrStdout(), format, repo, secret)
+	}
+
+	fmt.Fprintln(cmd.ErrOrStderr(), "You unsigned the secret!")
+	return nil
+}
+
+// RunSignKey signs a share that is encrypted to the user's SSH key.
+func RunSignKey(s ssh.Session, repo repository.Repository, cmd *cobra.Command, secret *model.Secret, ss *SplitState) error {
+	user := sessionUser(s)
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	if _, err := x25519Recipient(user.PublicKey); err != nil {
+		return err
+	}
+
+	if err := ss.Push(Passphrase{
+		UserID:    user.ID,
+		Username:  user.Username,
+		PublicKey: user.PublicKey,
+		Keys:      user.Verified,
+	}); err != nil {
+		return err
+	}
+
+	if format != "table" {
+		return writeSecret(cmd.OutOrStdout(), format, repo, secret)
+	}
+
+	fmt.Fprintln(cmd.ErrOrStderr(), "You signed the secret with your SSH key!")
+	return nil
+}
+
+// RunExportShares prints the user's shares that are encrypted to their SSH
+// key, one "{share id} {base64 ciphertext}" line each, for "ssss unwrap".
+func RunExportShares(cmd *cobra.Command, shares []model.Share) error {
+	for _, share := range shares {
+		fmt.Fprintf(cmd.OutOrStdout(), "%s %s\n", share.ID, base64.StdEncoding.EncodeToString(share.Share))
+	}
+
+	return nil
+}
+
+// RunUnsignKey reads the shares decrypted by "ssss unwrap" from stdin, one
+// "{share id} {base64 share}" line each.
+func RunUnsignKey(s ssh.Session, repo repository.Repository, cmd *cobra.Command, secret *model.Secret, cs *CombineState, shares []model.Share) error {
+	user := sessionUser(s)
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	unwrapped := map[string][]byte{}
+	in := bufio.NewReader(cmd.InOrStdin())
+	for {
+		line, err := in.ReadString('\n')
+		if id, data, ok := strings.Cut(strings.TrimSpace(line), " "); ok {
+			if share, err := base64.StdEncoding.DecodeString(data); err == nil {
+				unwrapped[id] = share
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if err := pushUnwrappedShare(cs, user, shares, unwrapped); err != nil {
+		return err
+	}
+
+	if format != "table" {
+		return writeSecret(cmd.OutOrStdout(), format, repo, secret)
+	}
+
+	fmt.Fprintln(cmd.ErrOrStderr(), "You unsigned the secret!")
+	return nil
+}
+
+// RunResharePlain waits for the new shareholders to sign and the current
+// ones to unsign, then reshares or refreshes the secret.
+func RunResharePlain(s ssh.Session, repo repository.Repository, cmd *cobra.Command, secret *model.Secret, rs *ReshareState) error {
+	out, errOut := cmd.OutOrStdout(), cmd.ErrOrStderr()
+
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+
+	if rs.Refresh {
+		fmt.Fprintf(errOut, "Ask the shareholders to sign their refreshed shares with: %s\n", signCommand(secret, true))
+		fmt.Fprintf(errOut, "Ask the shareholders to unsign every current share with: %s\n", unsignCommand(secret, true))
+	} else {
+		fmt.Fprintf(errOut, "Ask the new shareholders to sign their shares with: %s\n", signCommand(secret, true))
+		fmt.Fprintf(errOut, "Ask the current shareholders to unsign their shares with: %s\n", unsignCommand(secret, true))
+	}
+
+	ended := watchSession(s, rs)
+	defer ended()
+
+	for !rs.Done() {
+		var rejected *shareRejectedError
+		signing := rs.Split.Len() < rs.Split.Expected
+		p, err := rs.ReceiveOne()
+		if errors.Is(err, errCeremonyExpired) {
+			abortReshare(repo, secret, rs, true)
+			return err
+		} else if errors.Is(err, errCeremonyClosed) {
+			if ended() {
+				abortReshare(repo, secret, rs, false)
+			}
+			return err
+		} else if errors.As(err, &rejected) {
+			fmt.Fprintf(errOut, "Rejected a share from %s that failed verification\n", rejected.Username)
+			continue
+		} else if err != nil {
+			log.Error("Unable to persist ceremony", "id", secret.ID, "error", err)
+		}
+
+		if signing {
+			fmt.Fprintf(errOut, "Signed by %s (%d/%d)\n", p.Username, rs.Split.Len(), rs.Split.Expected)
+		} else {
+			fmt.Fprintf(errOut, "Unsigned by %s (%d/%d)\n", p.Username, rs.Combine.Len(), rs.Combine.Expected)
+		}
+	}
+
+	if ended() {
+		abortReshare(repo, secret, rs, false)
+		return errCeremonyClosed
+	}
+
+	if err := completeReshare(repo, secret, rs); err != nil {
+		return err
+	}
+
+	if format != "table" {
+		return writeSecret(out, format, repo, secret)
+	}
+
+	if rs.Refresh {
+		fmt.Fprintf(errOut, "Refreshed all %d shares\n", secret.Parts)
+		return nil
+	}
+	fmt.Fprintf(errOut, "Reshared into %d parts with a threshold of %d\n", secret.Parts, secret.Threshold)
+	return nil
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+)
+
+// finishRefresh rotates every share of the secret without recovering it.
+// Each refreshed share is encrypted with a passphrase signed by the
+// shareholder who unsigned the share it replaces, and stored as the next
+// generation. The current shares are revoked, and can't be combined with the
+// refreshed ones.
+func finishRefresh(repo repository.Repository, secret *model.Secret, rs *ReshareState) error {
+	shares := map[byte][]byte{}
+	for _, share := range rs.Combine.Shares {
+		shares[share.Key] = share.Share
+	}
+
+	refreshed, commitments, err := refreshShares(secret, shares)
+	if err != nil {
+		abortReshare(repo, secret, rs, false)
+		return err
+	}
+
+	current, err := repo.Share().ForSecret(secret.ID)
+	if err != nil {
+		abortReshare(repo, secret, rs, false)
+		return err
+	}
+
+	// Pair every refreshed share with a signature of its shareholder
+	signatures := map[string][]Passphrase{}
+	for _, pp := range rs.Split.Passphrases {
+		signatures[pp.UserID] = append(signatures[pp.UserID], pp)
+	}
+
+	generation := nextGeneration(current)
+	rotated := make([]model.Share, 0, len(rs.Combine.Shares))
+	for _, share := range rs.Combine.Shares {
+		pps := signatures[share.UserID]
+		if len(pps) == 0 {
+			abortReshare(repo, secret, rs, false)
+			return fmt.Errorf("%s unsigned a share without signing its refreshed share.", share.Username)
+		}
+		signatures[share.UserID] = pps[1:]
+
+		cipher, err := encryptShare(secret, pps[0], refreshed[share.Key])
+		if err != nil {
+			abortReshare(repo, secret, rs, false)
+			return err
+		}
+
+		rotated = append(rotated, model.Share{
+			Secret:     secret.ID,
+			User:       share.UserID,
+			Key:        share.Key,
+			Share:      cipher,
+			Generation: generation,
+		})
+	}
+
+	created := make([]model.Share, 0, len(rotated))
+	for _, share := range rotated {
+		s, err := repo.Share().Create(&share)
+		if err != nil {
+			revokeShares(repo, created)
+			abortReshare(repo, secret, rs, false)
+			return err
+		}
+		created = append(created, *s)
+	}
+
+	previous := *secret
+	secret.Commitments = commitments
+	return switchGeneration(repo, secret, &previous, rs, created, generation, model.EventSharesRefreshed)
+}
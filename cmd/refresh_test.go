@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+)
+
+func TestRefreshShares(t *testing.T) {
+	plaintext := []byte("a secret that takes more than one chunk")
+	secret := pedersenSecret(3, 2)
+	shares, err := splitSecret(secret, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, commitments, err := refreshShares(secret, shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous := *secret
+	secret.Commitments = commitments
+	for x, share := range refreshed {
+		if bytes.Equal(share, shares[x]) {
+			t.Fatalf("share %d wasn't rotated", x)
+		}
+		if err := verifyShare(secret, x, share); err != nil {
+			t.Fatalf("refreshed share %d: %v", x, err)
+		}
+
+		// The old shares only match the old commitments
+		if err := verifyShare(secret, x, shares[x]); err == nil {
+			t.Fatalf("old share %d verified against the new commitments", x)
+		}
+		if err := verifyShare(&previous, x, share); err == nil {
+			t.Fatalf("refreshed share %d verified against the old commitments", x)
+		}
+	}
+
+	recovered, err := combineSecret(secret, map[byte][]byte{1: refreshed[1], 3: refreshed[3]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered, plaintext) {
+		t.Fatalf("recovered %q, want %q", recovered, plaintext)
+	}
+
+	// Old and refreshed shares don't mix
+	mixed, err := combineSecret(secret, map[byte][]byte{1: shares[1], 3: refreshed[3]})
+	if err == nil && bytes.Equal(mixed, plaintext) {
+		t.Fatal("recovered the secret from old and refreshed shares")
+	}
+}
+
+func TestRefreshSignQuotas(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, _ := testUser(t, repo, "quota-alice")
+	bob, _ := testUser(t, repo, "quota-bob")
+	carol, _ := testUser(t, repo, "quota-carol")
+
+	secret := testSplit(t, repo, &model.Secret{Parts: 3, Threshold: 2, MultipleShares: true}, []byte("secret"),
+		[]model.User{alice, alice, bob}, []string{"pw-alice", "pw-alice", "pw-bob"})
+
+	target := *secret
+	rs, err := NewReshareState(repo, secret, &target, alice.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Close()
+
+	// Holders sign one refreshed share for each share they hold
+	for i := 0; i < 2; i++ {
+		if err := rs.Split.Push(passphraseOf(alice, "pw-alice")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rs.Split.Push(passphraseOf(alice, "pw-alice")); !errors.Is(err, errAlreadyRotated) {
+		t.Fatalf("err = %v, want %v", err, errAlreadyRotated)
+	}
+	if err := rs.Split.Push(passphraseOf(carol, "pw-carol")); !errors.Is(err, errAlreadyRotated) {
+		t.Fatalf("err = %v, want %v", err, errAlreadyRotated)
+	}
+	if err := rs.Split.Push(passphraseOf(bob, "pw-bob")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, _ := testUser(t, repo, "refresh-alice")
+	bob, _ := testUser(t, repo, "refresh-bob")
+
+	plaintext := []byte("secret")
+	secret := testSplit(t, repo, &model.Secret{Parts: 2, Threshold: 2}, plaintext,
+		[]model.User{alice, bob}, []string{"pw-alice", "pw-bob"})
+
+	target := *secret
+	rs, err := startReshare(repo, alice, secret, &target, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every holder signs their refreshed share, and unsigns their current one
+	for _, user := range []model.User{alice, bob} {
+		if err := rs.Split.Push(passphraseOf(user, "new-"+user.Username)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for rs.Split.Len() < rs.Split.Expected {
+		if _, err := rs.ReceiveOne(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	testUnsign(t, repo, rs.Combine, []model.User{alice, bob}, []string{"pw-alice", "pw-bob"})
+
+	if err := finishRefresh(repo, secret, rs); err != nil {
+		t.Fatal(err)
+	}
+	if secret.Status != "ready" || secret.Generation != 1 {
+		t.Fatalf("status = %q and generation = %d, want ready and 1", secret.Status, secret.Generation)
+	}
+	if _, ok := ReshareStates.Get(secret.ID); ok {
+		t.Fatal("refresh still registered")
+	}
+
+	shares, err := repo.Share().ForSecret(secret.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 2 {
+		t.Fatalf("got %d shares, want 2", len(shares))
+	}
+	for _, share := range shares {
+		if share.Generation != 1 {
+			t.Fatalf("share of generation %d left current", share.Generation)
+		}
+	}
+
+	// The refreshed shares open with the new passphrases only
+	cs, err := NewCombineState(repo, secret, alice.ID, secret.Threshold, sharesPerUser(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cs.Close()
+
+	mine, err := repo.Share().MineForSecret(secret.ID, bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unsignShare(repo, cs, bob, mine, "pw-bob"); !errors.Is(err, errNoShare) {
+		t.Fatalf("err = %v, want %v", err, errNoShare)
+	}
+	testUnsign(t, repo, cs, []model.User{alice, bob}, []string{"new-refresh-alice", "new-refresh-bob"})
+
+	recovered, err := recoverSecret(cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered, plaintext) {
+		t.Fatalf("recovered %q, want %q", recovered, plaintext)
+	}
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+	"github.com/spf13/viper"
+)
+
+// The registration policy, set with SSSS_REGISTRATION, decides who may
+// connect:
+//   - open lets anyone in, registering them on their first connection
+//   - authorized_keys only lets in the keys of SSSS_AUTHORIZED_KEYS_PATH
+//   - directory only lets in the keys of the .pub files in
+//     SSSS_AUTHORIZED_KEYS_DIR
+//   - approval registers anyone as pending, until an admin approves them
+//
+// The key files are read on every connection, so they can be edited without
+// a restart. Admins, listed in SSSS_ADMIN_KEYS, are always let in.
+const (
+	registrationOpen           = "open"
+	registrationAuthorizedKeys = "authorized_keys"
+	registrationDirectory      = "directory"
+	registrationApproval       = "approval"
+)
+
+// Users are active unless their registration is pending or was rejected, or
+// an admin disabled them. Users registered before there were statuses have
+// none, and are active.
+const (
+	userActive   = "active"
+	userPending  = "pending"
+	userRejected = "rejected"
+	userDisabled = "disabled"
+)
+
+var (
+	errRegistrationPending  = errors.New("Your registration is waiting for an admin's approval.")
+	errRegistrationRejected = errors.New("Your registration was rejected by an admin.")
+	errUserDisabled         = errors.New("Your account was disabled by an admin.")
+	errUsernameTaken        = errors.New("This username is already registered with other keys. Connect with one of them and add this key with \"keys add\".")
+	errKeyTaken             = errors.New("This key belongs to another user.")
+)
+
+// registered reports whether the registration policy lets the key connect.
+// Approval is checked once the user is looked up, since it is stored on them.
+func registered(key ssh.PublicKey) bool {
+	if isAdmin(userPublicKey(key)) {
+		return true
+	}
+
+	switch policy := viper.GetString("registration"); policy {
+	case registrationOpen, registrationApproval:
+		return true
+	case registrationAuthorizedKeys:
+		return keyListed(key, viper.GetString("authorized_keys_path"))
+	case registrationDirectory:
+		paths, err := filepath.Glob(filepath.Join(viper.GetString("authorized_keys_dir"), "*.pub"))
+		if err != nil {
+			log.Error("Could not read the authorized keys directory", "error", err)
+			return false
+		}
+
+		for _, path := range paths {
+			if keyListed(key, path) {
+				return true
+			}
+		}
+		return false
+	default:
+		log.Error("Unknown registration policy", "registration", policy)
+		return false
+	}
+}
+
+// keyListed reports whether the key is one of the keys in the authorized_keys
+// formatted file.
+func keyListed(key ssh.PublicKey, path string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Error("Could not read authorized keys", "path", path, "error", err)
+		return false
+	}
+
+	return containsKey(parseAuthorizedKeys(data), userPublicKey(key))
+}
+
+// parseAuthorizedKeys parses every key of authorized_keys formatted data,
+// encoded the way model.User.PublicKey is. Lines that aren't keys are
+// skipped.
+func parseAuthorizedKeys(data []byte) []string {
+	keys := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			continue
+		}
+
+		keys = append(keys, userPublicKey(key))
+	}
+
+	return keys
+}
+
+func containsKey(keys []string, publicKey string) bool {
+	for _, key := range keys {
+		if key == publicKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isAdmin reports whether the public key is one of the admin keys, given in
+// authorized_keys format and separated by commas or newlines.
+func isAdmin(publicKey string) bool {
+	admins := strings.ReplaceAll(viper.GetString("admin_keys"), ",", "\n")
+	return containsKey(parseAuthorizedKeys([]byte(admins)), publicKey)
+}
+
+// registerUser looks up the user owning the session's key, registering them
+// on their first connection. Under the approval policy, new users are
+// pending until an admin approves them. The user's PublicKey is set to the
+// session's key, whichever of their keys it is.
+func registerUser(repo repository.Repository, s ssh.Session) (*model.User, error) {
+	publicKey := userPublicKey(s.PublicKey())
+
+	// Keys added with "keys add" join their user when a session
+	// authenticated with them claims them
+	if args := s.Command(); len(args) == 3 && args[0] == "keys" && args[1] == "claim" {
+		if err := claimKey(repo, s.User(), publicKey, args[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	status := userActive
+	if viper.GetString("registration") == registrationApproval && !isAdmin(publicKey) {
+		status = userPending
+	}
+
+	user, err := repo.User().Upsert(&model.User{
+		Username:  s.User(),
+		PublicKey: publicKey,
+		Status:    status,
+	})
+	if errors.Is(err, repository.ErrUsernameTaken) {
+		return nil, errUsernameTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user.PublicKey = publicKey
+	return user, nil
+}
+
+// claimKey adds the key to the user that opened a claim on it with the
+// token.
+func claimKey(repo repository.Repository, username, publicKey, token string) error {
+	claim, ok := keyClaims.Redeem(token, username, publicKey)
+	if !ok {
+		return nil
+	}
+
+	switch owner, err := repo.User().ForKey(publicKey); {
+	case err == nil && owner.ID != claim.UserID:
+		return errKeyTaken
+	case err != nil && !errors.Is(err, repository.ErrNotFound):
+		return err
+	}
+
+	user, err := repo.User().Get(claim.UserID)
+	if err != nil {
+		return err
+	}
+
+	user.Verify(publicKey)
+	if err := repo.User().Update(user); err != nil {
+		return err
+	}
+
+	log.Info("Claimed a key", "user.id", user.ID, "fingerprint", displayShareholder(publicKey))
+	return nil
+}
+
+// admitted checks that a registered user may use the server. Pending users
+// are only held back under the approval policy.
+func admitted(user *model.User) error {
+	switch {
+	case user.Status == userRejected:
+		return errRegistrationRejected
+	case user.Status == userDisabled:
+		return errUserDisabled
+	case user.Status == userPending && viper.GetString("registration") == registrationApproval:
+		return errRegistrationPending
+	}
+
+	return nil
+}
+
+// userContextKey stores the user of a session in its context.
+type userContextKey struct{}
+
+// sessionUser returns the user of the session.
+func sessionUser(s ssh.Session) model.User {
+	return s.Context().Value(userContextKey{}).(model.User)
+}
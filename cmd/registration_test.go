@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/ssh"
+	"github.com/spf13/viper"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// testSession is a session of the username, connected with the key.
+type testSession struct {
+	ssh.Session
+	ctx     *testContext
+	key     ssh.PublicKey
+	command []string
+}
+
+func newTestSession(username string, key ssh.PublicKey, command ...string) *testSession {
+	return &testSession{ctx: newTestContext(username), key: key, command: command}
+}
+
+func (s *testSession) Context() ssh.Context     { return s.ctx }
+func (s *testSession) User() string             { return s.ctx.user }
+func (s *testSession) PublicKey() ssh.PublicKey { return s.key }
+func (s *testSession) Command() []string        { return s.command }
+
+// newSSHKey generates an ssh-ed25519 key.
+func newSSHKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sshKey(t, pub)
+}
+
+func writeKeys(t *testing.T, path string, keys ...ssh.PublicKey) {
+	t.Helper()
+
+	data := []byte("# keys let in by the tests\n")
+	for _, key := range keys {
+		data = append(data, gossh.MarshalAuthorizedKey(key)...)
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	admin, listed, unlisted := newSSHKey(t), newSSHKey(t), newSSHKey(t)
+
+	dir := t.TempDir()
+	authorizedKeys := filepath.Join(dir, "authorized_keys")
+	writeKeys(t, authorizedKeys, listed)
+
+	// Only the .pub files of the directory are read
+	keysDir := filepath.Join(dir, "keys")
+	if err := os.Mkdir(keysDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeKeys(t, filepath.Join(keysDir, "listed.pub"), listed)
+	writeKeys(t, filepath.Join(keysDir, "unlisted.txt"), unlisted)
+
+	viper.Set("admin_keys", string(gossh.MarshalAuthorizedKey(admin)))
+	viper.Set("authorized_keys_path", authorizedKeys)
+	viper.Set("authorized_keys_dir", keysDir)
+	defer func() {
+		viper.Set("registration", registrationOpen)
+		viper.Set("admin_keys", "")
+		viper.Set("authorized_keys_path", "")
+		viper.Set("authorized_keys_dir", "")
+	}()
+
+	for _, c := range []struct {
+		policy                 string
+		listed, unlisted, none bool
+	}{
+		{registrationOpen, true, true, true},
+		{registrationApproval, true, true, true},
+		{registrationAuthorizedKeys, true, false, false},
+		{registrationDirectory, true, false, false},
+		{"unknown", false, false, false},
+	} {
+		viper.Set("registration", c.policy)
+
+		// Admins are always let in
+		if !registered(admin) {
+			t.Errorf("%s: admin refused", c.policy)
+		}
+		if got := registered(listed); got != c.listed {
+			t.Errorf("%s: listed key registered = %v, want %v", c.policy, got, c.listed)
+		}
+		if got := registered(unlisted); got != c.unlisted {
+			t.Errorf("%s: unlisted key registered = %v, want %v", c.policy, got, c.unlisted)
+		}
+
+		// Missing key files let nobody in but admins
+		viper.Set("authorized_keys_path", filepath.Join(dir, "missing"))
+		viper.Set("authorized_keys_dir", filepath.Join(dir, "missing"))
+		if got := registered(listed); got != c.none {
+			t.Errorf("%s: key registered without key files = %v, want %v", c.policy, got, c.none)
+		}
+		if !registered(admin) {
+			t.Errorf("%s: admin refused without key files", c.policy)
+		}
+		viper.Set("authorized_keys_path", authorizedKeys)
+		viper.Set("authorized_keys_dir", keysDir)
+	}
+}
+
+func TestAdmitted(t *testing.T) {
+	defer viper.Set("registration", registrationOpen)
+
+	for _, policy := range []string{registrationOpen, registrationAuthorizedKeys, registrationDirectory, registrationApproval} {
+		viper.Set("registration", policy)
+
+		for _, c := range []struct {
+			status string
+			err    error
+		}{
+			{"", nil},
+			{userActive, nil},
+			{userRejected, errRegistrationRejected},
+			{userDisabled, errUserDisabled},
+		} {
+			if err := admitted(&model.User{Status: c.status}); !errors.Is(err, c.err) {
+				t.Errorf("%s: %q user: err = %v, want %v", policy, c.status, err, c.err)
+			}
+		}
+
+		// Pending users are only held back under the approval policy, so
+		// switching to another one lets them in
+		var want error
+		if policy == registrationApproval {
+			want = errRegistrationPending
+		}
+		if err := admitted(&model.User{Status: userPending}); !errors.Is(err, want) {
+			t.Errorf("%s: pending user: err = %v, want %v", policy, err, want)
+		}
+	}
+}
+
+func TestRegisterUser(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	admin := newSSHKey(t)
+
+	viper.Set("admin_keys", string(gossh.MarshalAuthorizedKey(admin)))
+	defer func() {
+		viper.Set("registration", registrationOpen)
+		viper.Set("admin_keys", "")
+	}()
+
+	for _, c := range []struct {
+		policy   string
+		username string
+		key      ssh.PublicKey
+		status   string
+		err      error
+	}{
+		{registrationOpen, "register-open", newSSHKey(t), userActive, nil},
+		{registrationApproval, "register-pending", newSSHKey(t), userPending, errRegistrationPending},
+		{registrationApproval, "register-admin", admin, userActive, nil},
+	} {
+		viper.Set("registration", c.policy)
+
+		user, err := registerUser(repo, newTestSession(c.username, c.key))
+		if err != nil {
+			t.Fatalf("%s: %v", c.username, err)
+		}
+		if user.Username != c.username || user.Status != c.status {
+			t.Fatalf("%s: registered %s as %q, want %q", c.username, user.Username, user.Status, c.status)
+		}
+		if err := admitted(user); !errors.Is(err, c.err) {
+			t.Fatalf("%s: err = %v, want %v", c.username, err, c.err)
+		}
+
+		// Connecting again finds the same user, who stays as they were
+		again, err := registerUser(repo, newTestSession(c.username, c.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again.ID != user.ID || again.Status != c.status {
+			t.Fatalf("%s: reconnected as %s, %q", c.username, again.ID, again.Status)
+		}
+	}
+
+	// Another key can't take a registered username
+	if _, err := registerUser(repo, newTestSession("register-open", newSSHKey(t))); !errors.Is(err, errUsernameTaken) {
+		t.Fatalf("err = %v, want %v", err, errUsernameTaken)
+	}
+}
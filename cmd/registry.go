@@ -0,0 +1,79 @@
+package cmd
+
+import "sync"
+
+// registry holds the running ceremonies of one kind, by the ID of their
+// secret. Every SSH session runs in its own goroutine, so the ceremonies are
+// only ever reached through the registry's lock.
+type registry[T comparable] struct {
+	mu     sync.RWMutex
+	states map[string]T
+}
+
+func newRegistry[T comparable]() *registry[T] {
+	return &registry[T]{states: map[string]T{}}
+}
+
+// Get returns the ceremony running for the secret.
+func (r *registry[T]) Get(id string) (T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state, ok := r.states[id]
+	return state, ok
+}
+
+// Add registers the ceremony running for the secret, unless another one
+// already runs for it.
+func (r *registry[T]) Add(id string, state T) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.states[id]; ok {
+		return false
+	}
+
+	r.states[id] = state
+	return true
+}
+
+// Delete unregisters the ceremony of the secret, unless it was already
+// replaced by another one.
+func (r *registry[T]) Delete(id string, state T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.states[id] == state {
+		delete(r.states, id)
+	}
+}
+
+// Snapshot copies the running ceremonies, so they can be ranged over while
+// others start and stop.
+func (r *registry[T]) Snapshot() map[string]T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := make(map[string]T, len(r.states))
+	for id, state := range r.states {
+		states[id] = state
+	}
+
+	return states
+}
+
+// runningCeremonies is a snapshot of every running ceremony, taken once by
+// views that look up the ceremonies of many secrets.
+type runningCeremonies struct {
+	splits   map[string]*SplitState
+	combines map[string]*CombineState
+	reshares map[string]*ReshareState
+}
+
+func snapshotCeremonies() runningCeremonies {
+	return runningCeremonies{
+		splits:   SplitStates.Snapshot(),
+		combines: CombineStates.Snapshot(),
+		reshares: ReshareStates.Snapshot(),
+	}
+}
@@ -0,0 +1,315 @@
+package cmd
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/bubbles/progress"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/charmbracelet/ssh"
+)
+
+// startReshare opens the reshare, or refresh, ceremonies of a ready secret.
+// The target holds the parts, threshold and shareholders of the new split.
+func startReshare(repo repository.Repository, user model.User, secret *model.Secret, target *model.Secret, refresh bool) (*ReshareState, error) {
+	rs, err := NewReshareState(repo, secret, target, user.ID, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	status, action := "resharing", model.EventReshareStarted
+	if refresh {
+		status, action = "refreshing", model.EventRefreshStarted
+	}
+
+	secret.Status = status
+	if err := repo.Secret().Update(secret); err != nil {
+		rs.Close()
+		return nil, err
+	}
+
+	log.Info("Resharing a Secret", "id", secret.ID, "user", user.ID, "parts", target.Parts, "threshold", target.Threshold, "refresh", refresh)
+	recordEvent(repo, secret.ID, user.ID, action)
+	return rs, nil
+}
+
+// finishReshare recovers the secret from the current shares and, in the same
+// step, splits it again for the new shareholders. The plaintext never leaves
+// the server's memory. The current shares are revoked once the new ones are
+// stored.
+func finishReshare(repo repository.Repository, secret *model.Secret, rs *ReshareState) error {
+	plaintext, err := recoverSecret(rs.Combine)
+	if err != nil {
+		abortReshare(repo, secret, rs, false)
+		return err
+	}
+
+	current, err := repo.Share().ForSecret(secret.ID)
+	if err != nil {
+		abortReshare(repo, secret, rs, false)
+		return err
+	}
+
+	// Secrets are always reshared with the verifiable scheme
+	previous := *secret
+	secret.Parts = rs.Target.Parts
+	secret.Threshold = rs.Target.Threshold
+	secret.Shareholders = rs.Target.Shareholders
+	secret.Scheme = schemePedersen
+
+	generation := nextGeneration(current)
+	created, err := storeShares(repo, secret, rs.Split, plaintext, generation)
+	if err != nil {
+		*secret = previous
+		abortReshare(repo, secret, rs, false)
+		return err
+	}
+
+	return switchGeneration(repo, secret, &previous, rs, created, generation, model.EventSecretReshared)
+}
+
+// switchGeneration points the secret at the shares a reshare or refresh
+// created, then revokes the ones they replace and marks the secret ready.
+// The secret keeps its resharing or refreshing status, and its ceremonies,
+// until the end, so no other ceremony starts halfway and ExpireCeremonies
+// finishes the job if the server stops.
+func switchGeneration(repo repository.Repository, secret *model.Secret, previous *model.Secret, rs *ReshareState, created []model.Share, generation int, action string) error {
+	secret.Generation = generation
+	if err := repo.Secret().Update(secret); err != nil {
+		*secret = *previous
+		revokeShares(repo, created)
+		abortReshare(repo, secret, rs, false)
+		return err
+	}
+	defer rs.Close()
+
+	if err := revokeStaleShares(repo, secret); err != nil {
+		return err
+	}
+
+	secret.Status = "ready"
+	if err := repo.Secret().Update(secret); err != nil {
+		return err
+	}
+
+	recordEvent(repo, secret.ID, rs.Combine.ceremony.User, action)
+	return nil
+}
+
+// abortReshare tears down both ceremonies of a reshare or refresh. The
+// secret keeps its current shares and is ready again.
+func abortReshare(repo repository.Repository, secret *model.Secret, rs *ReshareState, expired bool) error {
+	action := model.EventReshareCancelled
+	switch {
+	case rs.Refresh && expired:
+		action = model.EventRefreshExpired
+	case rs.Refresh:
+		action = model.EventRefreshCancelled
+	case expired:
+		action = model.EventReshareExpired
+	}
+
+	rs.Close()
+	secret.Status = "ready"
+	recordEvent(repo, secret.ID, rs.Combine.ceremony.User, action)
+	return repo.Secret().Update(secret)
+}
+
+// completeReshare finishes a reshare or a refresh, once every signature and
+// share were received.
+func completeReshare(repo repository.Repository, secret *model.Secret, rs *ReshareState) error {
+	if rs.Refresh {
+		return finishRefresh(repo, secret, rs)
+	}
+
+	return finishReshare(repo, secret, rs)
+}
+
+// nextGeneration returns the generation following the given shares'.
+func nextGeneration(shares []model.Share) int {
+	generation := 0
+	for _, share := range shares {
+		if share.Generation >= generation {
+			generation = share.Generation + 1
+		}
+	}
+
+	return generation
+}
+
+// revokeStaleShares revokes the current shares of the secret that aren't of
+// its generation, left behind by a reshare or refresh that didn't finish.
+// Secrets none of whose shares are of their generation are left alone,
+// rather than losing every share.
+func revokeStaleShares(repo repository.Repository, secret *model.Secret) error {
+	shares, err := repo.Share().ForSecret(secret.ID)
+	if err != nil {
+		return err
+	}
+
+	recorded := false
+	stale := []model.Share{}
+	for _, share := range shares {
+		if share.Generation == secret.Generation {
+			recorded = true
+			continue
+		}
+		stale = append(stale, share)
+	}
+	if !recorded || len(stale) == 0 {
+		return nil
+	}
+
+	log.Info("Revoking stale shares", "id", secret.ID, "generation", secret.Generation, "shares", len(stale))
+	return revokeShares(repo, stale)
+}
+
+// revokeShares marks shares replaced by a reshare or refresh as revoked.
+func revokeShares(repo repository.Repository, shares []model.Share) error {
+	for _, share := range shares {
+		share.Revoked = true
+		if err := repo.Share().Update(&share); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func RunReshareProgram(s ssh.Session, repo repository.Repository, secret *model.Secret, rs *ReshareState) error {
+	pty, _, ok := s.Pty()
+	if !ok {
+		return errNoTerminal
+	}
+
+	holders, err := shareHolders(repo, secret)
+	if err != nil {
+		return err
+	}
+
+	reshareTUI := ReshareTUI{
+		TUI:          NewTUI(s),
+		repo:         repo,
+		progress:     progress.New(progress.WithWidth(pty.Window.Width-2), progress.WithoutPercentage()),
+		secret:       secret,
+		reshareState: rs,
+		holders:      holders,
+		names:        shareholderNames(repo, rs.Target.Shareholders),
+	}
+
+	_, err = newProgram(s, reshareTUI).Run()
+	return err
+}
+
+type ReshareTUI struct {
+	TUI
+	repo     repository.Repository
+	progress progress.Model
+
+	secret       *model.Secret
+	reshareState *ReshareState
+	holders      map[string]string
+	names        map[string]string
+	rejected     []string
+	expired      bool
+	done         bool
+	err          error
+}
+
+func (t ReshareTUI) Init() tea.Cmd {
+	return tea.Batch(
+		receive(t.reshareState),
+		t.TUI.Init(),
+	)
+}
+
+func (t ReshareTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case receiveMsg:
+		if t.reshareState.Done() {
+			return t, receivedAll
+		}
+
+		return t, receive(t.reshareState)
+	case rejectedMsg:
+		t.rejected = append(t.rejected, msg.username)
+		return t, receive(t.reshareState)
+	case expiredMsg:
+		abortReshare(t.repo, t.secret, t.reshareState, true)
+		t.expired = true
+		return t, tea.Quit
+	case closedMsg:
+		t.secret.Status = "ready"
+		t.err = errCeremonyClosed
+		return t, tea.Quit
+	case receivedAllMsg:
+		t.err = completeReshare(t.repo, t.secret, t.reshareState)
+		t.done = true
+		return t, tea.Quit
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			if !t.done && t.secret.Status != "ready" {
+				abortReshare(t.repo, t.secret, t.reshareState, false)
+			}
+		}
+	}
+
+	tui, cmd := t.TUI.Update(msg)
+	if tui, ok := tui.(TUI); ok {
+		t.TUI = tui
+		if cmd != nil {
+			return t, cmd
+		}
+	}
+
+	return t, nil
+}
+
+func (t ReshareTUI) View() string {
+	v := NewView()
+	rs := t.reshareState
+
+	switch {
+	case t.err != nil:
+		v.Colorf(lipgloss.Color("#F00"), "%s", t.err.Error())
+	case t.expired:
+		v.Colorf(lipgloss.Color("#F00"), errCeremonyExpired.Error())
+	case t.done && rs.Refresh:
+		v.Colorf(lipgloss.Color("#0F0"), "Refreshed all %d shares", t.secret.Parts)
+	case t.done:
+		v.Colorf(lipgloss.Color("#0F0"), "Reshared into %d parts with a threshold of %d", t.secret.Parts, t.secret.Threshold)
+	default:
+		if rs.Refresh {
+			v.WriteString("Ask the shareholders to sign their refreshed shares with: ")
+		} else {
+			v.WriteString("Ask the new shareholders to sign their shares with: ")
+		}
+		v.Colorf(lipgloss.Color("#0F0"), "%s", signCommand(t.secret, false))
+		v.NL()
+		v.WriteString(t.progress.ViewAs(float64(rs.Split.Len()) / float64(rs.Split.Expected)))
+		v.NL()
+		writeRoster(v, rs.Split.Participants(), rs.Split.Expected, pendingShareholders(rs.Split, rs.Target.Shareholders, t.names))
+
+		v.NL()
+		v.NL()
+		if rs.Refresh {
+			v.WriteString("Ask the shareholders to unsign every current share with: ")
+		} else {
+			v.WriteString("Ask the current shareholders to unsign their shares with: ")
+		}
+		v.Colorf(lipgloss.Color("#0F0"), "%s", unsignCommand(t.secret, false))
+		v.NL()
+		v.WriteString(t.progress.ViewAs(float64(rs.Combine.Len()) / float64(rs.Combine.Expected)))
+		v.NL()
+		writeRoster(v, rs.Combine.Participants(), rs.Combine.Expected, pendingHolders(rs.Combine, t.holders))
+
+		for _, username := range t.rejected {
+			v.NL()
+			v.Colorf(lipgloss.Color("#F00"), "Rejected a share from %s that failed verification", username)
+		}
+	}
+
+	return t.renderer.NewStyle().Width(t.width-2).Border(lipgloss.RoundedBorder(), true).Render(v.String()) + "\n"
+}
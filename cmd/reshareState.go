@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/spf13/viper"
+)
+
+var ReshareStates = newRegistry[*ReshareState]()
+
+// ReshareState pairs the two halves of a reshare: the new shareholders sign
+// their shares of the new split, while the current shareholders unsign
+// theirs. Both ceremonies run at once and share a deadline.
+//
+// A refresh is a reshare onto the same shareholders that never recovers the
+// secret: every current share is unsigned, and rotated for a new one signed
+// by the same shareholder.
+type ReshareState struct {
+	repo repository.Repository
+
+	// Target holds the parts, threshold and shareholders of the new split
+	Target  *model.Secret
+	Split   *SplitState
+	Combine *CombineState
+	Refresh bool
+}
+
+func NewReshareState(repo repository.Repository, secret *model.Secret, target *model.Secret, userId string, refresh bool) (*ReshareState, error) {
+	// A refresh rotates every share, so it needs all of them, signed and
+	// unsigned by whoever holds them
+	expected, signers, unsigners := secret.Threshold, sharesPerUser(target), sharesPerUser(secret)
+	if refresh {
+		expected, signers, unsigners = secret.Parts, 0, 0
+	}
+
+	// Refreshed shares are only signed by their current holders, one for
+	// each share they hold
+	var quotas map[string]int
+	if refresh {
+		shares, err := repo.Share().ForSecret(secret.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		quotas = map[string]int{}
+		for _, share := range shares {
+			quotas[share.User]++
+		}
+	}
+
+	ss, err := NewSplitState(repo, secret.ID, userId, target.Parts, signers)
+	if err != nil {
+		return nil, err
+	}
+	ss.Quotas = quotas
+
+	cs, err := NewCombineState(repo, secret, userId, expected, unsigners)
+	if err != nil {
+		ss.Close()
+		return nil, err
+	}
+
+	rs := &ReshareState{
+		repo:    repo,
+		Target:  target,
+		Split:   ss,
+		Combine: cs,
+		Refresh: refresh,
+	}
+
+	deadline := time.Now().Add(viper.GetDuration("reshare_timeout"))
+	ss.Deadline, ss.ceremony.ExpiresAt = deadline, deadline
+	cs.Deadline, cs.ceremony.ExpiresAt = deadline, deadline
+	if err := repo.Ceremony().Update(ss.ceremony); err != nil {
+		rs.Close()
+		return nil, err
+	}
+	if err := repo.Ceremony().Update(cs.ceremony); err != nil {
+		rs.Close()
+		return nil, err
+	}
+
+	if !ReshareStates.Add(secret.ID, rs) {
+		ss.Close()
+		cs.Close()
+		return nil, errInCeremony
+	}
+
+	return rs, nil
+}
+
+// ReceiveOne receives the next signature of the new split, then the next
+// share of the current one once every new shareholder signed. It returns
+// who signed or unsigned.
+func (r *ReshareState) ReceiveOne() (model.Participant, error) {
+	if r.Split.Len() < r.Split.Expected {
+		return r.Split.ReceiveOne()
+	}
+
+	return r.Combine.ReceiveOne()
+}
+
+// Done reports whether both ceremonies received everything they expected.
+func (r *ReshareState) Done() bool {
+	return r.Split.Len() == r.Split.Expected && r.Combine.Len() == r.Combine.Expected
+}
+
+// Close tears down both ceremonies.
+func (r *ReshareState) Close() error {
+	ReshareStates.Delete(r.Combine.SecretID, r)
+
+	err := r.Split.Close()
+	if cerr := r.Combine.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"net"
 	"os"
@@ -10,36 +9,31 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/adamgoose/ssss/lib/model"
 	"github.com/adamgoose/ssss/lib/repository"
 	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
-	"github.com/charmbracelet/wish/activeterm"
 	"github.com/charmbracelet/wish/logging"
 	"github.com/spf13/viper"
 )
 
 func RunE(repo repository.Repository) error {
+	if err := ExpireCeremonies(repo); err != nil {
+		log.Error("Could not expire ceremonies", "error", err)
+		return err
+	}
+
 	s, err := wish.NewServer(
 		wish.WithAddress(net.JoinHostPort(
 			viper.GetString("host"),
 			viper.GetString("port"),
 		)),
 		wish.WithHostKeyPath(viper.GetString("host_key_path")),
-		wish.WithPublicKeyAuth(func(ctx ssh.Context, key ssh.PublicKey) bool {
-			return key.Type() == "ssh-ed25519"
-		}),
+		wish.WithPublicKeyAuth(publicKeyAuth),
 		wish.WithMiddleware(
 			func(next ssh.Handler) ssh.Handler {
 				return func(sess ssh.Session) {
-					rootCmd := NewSSHCmd(sess)
-					rootCmd.SetArgs(sess.Command())
-					rootCmd.SetIn(sess)
-					rootCmd.SetOut(sess)
-					rootCmd.SetErr(sess.Stderr())
-					rootCmd.CompletionOptions.DisableDefaultCmd = true
-					if err := rootCmd.Execute(); err != nil {
+					if err := executeSSHCmd(sess, sess.Command()); err != nil {
 						_ = sess.Exit(1)
 						return
 					}
@@ -47,20 +41,27 @@ func RunE(repo repository.Repository) error {
 					next(sess)
 				}
 			},
-			activeterm.Middleware(),
 			func(next ssh.Handler) ssh.Handler {
 				return func(s ssh.Session) {
-					user, err := repo.User().Upsert(&model.User{
-						Username:  s.User(),
-						PublicKey: base64.StdEncoding.EncodeToString(s.PublicKey().Marshal()),
-					})
+					user, err := registerUser(repo, s)
+					if errors.Is(err, errUsernameTaken) || errors.Is(err, errKeyTaken) {
+						log.Info("Username or key taken by another user", "user.name", s.User())
+						wish.Fatalln(s, err)
+						return
+					}
 					if err != nil {
 						log.Error("unable to create user", "error", err)
 						return
 					}
 
+					if err := admitted(user); err != nil {
+						log.Info("User not admitted", "user.name", user.Username, "user.id", user.ID, "status", user.Status)
+						wish.Fatalln(s, err)
+						return
+					}
+
 					log.Info("User authenticated", "user.name", user.Username, "user.id", user.ID)
-					s.Context().SetValue(model.User{}, *user)
+					s.Context().SetValue(userContextKey{}, *user)
 
 					next(s)
 				}
@@ -92,3 +93,16 @@ func RunE(repo repository.Repository) error {
 	}
 	return nil
 }
+
+// executeSSHCmd runs the command line of the session, or one launched from
+// the dashboard.
+func executeSSHCmd(sess ssh.Session, args []string) error {
+	rootCmd := NewSSHCmd(sess)
+	rootCmd.SetArgs(args)
+	rootCmd.SetIn(sess)
+	rootCmd.SetOut(sess)
+	rootCmd.SetErr(sess.Stderr())
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+
+	return rootCmd.Execute()
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// writeRoster renders who joined a ceremony and when, followed by how many
+// more it waits on and from whom. Nothing is ever shown about the
+// passphrases or shares they brought.
+func writeRoster(v *View, joined []model.Participant, expected int, waiting []string) {
+	v.WriteString(fmt.Sprintf("Joined (%d/%d):", len(joined), expected))
+
+	width := 0
+	for _, p := range joined {
+		width = max(width, len(p.Username))
+	}
+
+	for _, p := range joined {
+		v.NL()
+		v.Colorf(lipgloss.Color("#0F0"), "  ✓ %-*s", width, p.Username)
+		v.WriteString("  " + p.JoinedAt.Format(timeFormat))
+	}
+
+	if remaining := expected - len(joined); remaining > 0 {
+		v.NL()
+		v.Colorf(lipgloss.Color("#FF0"), "  … waiting on %d more", remaining)
+		if len(waiting) > 0 {
+			v.WriteString(" from " + strings.Join(waiting, ", "))
+		}
+	}
+}
+
+// pendingShareholders lists the designated shareholders of a split that have
+// yet to sign, by the names resolved with shareholderNames.
+func pendingShareholders(ss *SplitState, shareholders []string, names map[string]string) []string {
+	pending := ss.Pending(shareholders)
+	for i, id := range pending {
+		pending[i] = names[id]
+	}
+
+	return pending
+}
+
+// pendingHolders lists the holders of the secret's shares that have yet to
+// unsign.
+func pendingHolders(cs *CombineState, holders map[string]string) []string {
+	joined := map[string]bool{}
+	for _, p := range cs.Participants() {
+		joined[p.User] = true
+	}
+
+	pending := []string{}
+	for id, username := range holders {
+		if !joined[id] {
+			pending = append(pending, username)
+		}
+	}
+	sort.Strings(pending)
+
+	return pending
+}
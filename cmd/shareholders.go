@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// normalizeShareholders converts the shareholders given to split into the
+// identifiers stored on a secret. Public keys in authorized_keys format are
+// stored the way model.User.PublicKey is, and usernames are resolved to the
+// ID of the user registered under them, so that nobody registering the name
+// later is mistaken for them. Shareholders named twice are stored once.
+func normalizeShareholders(repo repository.Repository, shareholders []string) ([]string, error) {
+	var users []model.User
+
+	ids := make([]string, 0, len(shareholders))
+	for _, sh := range shareholders {
+		sh = strings.TrimSpace(sh)
+		if sh == "" {
+			continue
+		}
+
+		if key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(sh)); err == nil {
+			ids = appendUnique(ids, base64.StdEncoding.EncodeToString(key.Marshal()))
+			continue
+		}
+
+		if users == nil {
+			var err error
+			if users, err = repo.User().List(); err != nil {
+				return nil, err
+			}
+		}
+
+		id, err := resolveUsername(users, sh)
+		if err != nil {
+			return nil, err
+		}
+		ids = appendUnique(ids, id)
+	}
+
+	return ids, nil
+}
+
+// appendUnique appends the identifier, unless it's already there.
+func appendUnique(ids []string, id string) []string {
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+
+	return append(ids, id)
+}
+
+// resolveUsername finds the ID of the user registered under the username.
+func resolveUsername(users []model.User, username string) (string, error) {
+	id := ""
+	for _, user := range users {
+		if user.Username != username || user.Status == userRejected {
+			continue
+		}
+		if id != "" {
+			return "", fmt.Errorf("Several users are registered as %s, name them by their public key instead.", username)
+		}
+		id = user.ID
+	}
+
+	if id == "" {
+		return "", fmt.Errorf("No user is registered as %s, name them by their public key instead.", username)
+	}
+
+	return id, nil
+}
+
+// resolveShareholders resolves the usernames still stored on secrets split
+// before shareholders were resolved, as designated by the secret.
+func resolveShareholders(repo repository.Repository, secret *model.Secret, shareholders []string) ([]string, error) {
+	users, err := repo.User().List()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(shareholders))
+	for _, id := range shareholders {
+		for _, user := range users {
+			if !isShareholder(id, user) && designates(secret, id, user) {
+				id = user.ID
+				break
+			}
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// isShareholder reports whether the identifier designates the user, by
+// their ID or any of their keys.
+func isShareholder(identifier string, user model.User) bool {
+	return identifier == user.ID || user.HasKey(identifier)
+}
+
+// designates reports whether an identifier stored on the secret designates
+// the user. Secrets split before shareholders were resolved store usernames,
+// which only designate users registered by the time the secret was split.
+func designates(secret *model.Secret, identifier string, user model.User) bool {
+	if isShareholder(identifier, user) {
+		return true
+	}
+
+	return identifier == user.Username && !user.FirstSeen.After(secret.CreatedAt)
+}
+
+// canSign reports whether the user may sign the secret. Secrets without
+// designated shareholders may be signed by anyone.
+func canSign(secret *model.Secret, user model.User) bool {
+	if len(secret.Shareholders) == 0 {
+		return true
+	}
+
+	for _, id := range secret.Shareholders {
+		if designates(secret, id, user) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// shareholderNames renders the shareholder identifiers stored on a secret
+// for humans, by the username of the users they designate, or by fingerprint
+// for keys. Users that no longer resolve are shown by their ID.
+func shareholderNames(repo repository.Repository, identifiers []string) map[string]string {
+	names := make(map[string]string, len(identifiers))
+	for _, id := range identifiers {
+		names[id] = displayShareholder(id)
+		if !strings.HasPrefix(id, "users:") {
+			continue
+		}
+
+		if user, err := repo.User().Get(id); err == nil && user.Username != "" {
+			names[id] = user.Username
+		}
+	}
+
+	return names
+}
+
+// displayShareholder renders a shareholder identifier for humans, showing
+// public keys by their fingerprint.
+func displayShareholder(identifier string) string {
+	key, err := parseUserKey(identifier)
+	if err != nil {
+		return identifier
+	}
+
+	return gossh.FingerprintSHA256(key)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestNormalizeShareholders(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, priv := testUser(t, repo, "normalize-alice")
+
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorizedKey := string(gossh.MarshalAuthorizedKey(signer.PublicKey()))
+
+	// Usernames resolve to IDs, keys are stored like model.User.PublicKey,
+	// and shareholders named twice are stored once
+	ids, err := normalizeShareholders(repo, []string{"normalize-alice", " ", authorizedKey, "normalize-alice", authorizedKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{alice.ID, alice.PublicKey}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+
+	if _, err := normalizeShareholders(repo, []string{"normalize-nobody"}); err == nil {
+		t.Fatal("normalized a username nobody registered")
+	}
+}
+
+func TestSecretOutputRecipients(t *testing.T) {
+	repo := lib.MustAutoResolve[repository.Repository]()
+	alice, _ := testUser(t, repo, "output-alice")
+	bob, _ := testUser(t, repo, "output-bob")
+
+	gone := repository.NewID("users")
+	secret, err := repo.Secret().Create(&model.Secret{
+		User:       alice.ID,
+		Parts:      2,
+		Threshold:  2,
+		Status:     "ready",
+		Recipients: []string{bob.ID, bob.ID, gone},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Recipients named twice are listed once, and those that no longer
+	// resolve by their ID
+	out, err := newSecretOutput(repo, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"output-bob", gone}; !reflect.DeepEqual(out.Recipients, want) {
+		t.Fatalf("recipients = %v, want %v", out.Recipients, want)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -13,7 +14,6 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/ssh"
-	"github.com/corvus-ch/shamir"
 	"github.com/spf13/cobra"
 )
 
@@ -22,6 +22,9 @@ type (
 	cancelMsg      struct{}
 	receiveMsg     struct{}
 	receivedAllMsg struct{}
+	expiredMsg     struct{}
+	closedMsg      struct{}
+	rejectedMsg    struct{ username string }
 )
 
 func submit() tea.Msg {
@@ -33,11 +36,25 @@ func cancel() tea.Msg {
 }
 
 func receive(state interface {
-	ReceiveOne() error
+	ReceiveOne() (model.Participant, error)
 },
 ) tea.Cmd {
 	return func() tea.Msg {
-		state.ReceiveOne()
+		_, err := state.ReceiveOne()
+		if errors.Is(err, errCeremonyExpired) {
+			return expiredMsg{}
+		}
+
+		// The ceremony was torn down under us, by an admin
+		if errors.Is(err, errCeremonyClosed) {
+			return closedMsg{}
+		}
+
+		var rejected *shareRejectedError
+		if errors.As(err, &rejected) {
+			return rejectedMsg{rejected.Username}
+		}
+
 		return receiveMsg{}
 	}
 }
@@ -46,18 +63,183 @@ func receivedAll() tea.Msg {
 	return receivedAllMsg{}
 }
 
+// startSplit creates the secret and opens its split ceremony, signed first
+// by the user splitting it.
+func startSplit(repo repository.Repository, user model.User, secret *model.Secret, passphrase string) (*model.Secret, *SplitState, error) {
+	secret.User = user.ID
+	secret.Scheme = schemePedersen
+	secret.Status = "signing"
+	secret.CreatedAt = time.Now()
+
+	s, err := repo.Secret().Create(secret)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.Info("Splitting a Secret", "id", s.ID, "user", user.ID)
+	recordEvent(repo, s.ID, user.ID, model.EventSecretCreated)
+
+	ss, err := NewSplitState(repo, s.ID, user.ID, s.Parts, sharesPerUser(s))
+	if err != nil {
+		s.Status = "dead"
+		repo.Secret().Update(s)
+		return nil, nil, err
+	}
+
+	if err := ss.Push(Passphrase{
+		UserID:     user.ID,
+		Username:   user.Username,
+		PublicKey:  user.PublicKey,
+		Keys:       user.Verified,
+		Passphrase: passphrase,
+	}); err != nil {
+		abortSplit(repo, s, ss)
+		return nil, nil, err
+	}
+
+	return s, ss, nil
+}
+
+// finishSplit stores the shares of a new secret and marks it ready. When it
+// fails, the shares it already stored are revoked, and the ceremony is left
+// for the caller to tear down.
+func finishSplit(repo repository.Repository, secret *model.Secret, ss *SplitState, plaintext []byte) error {
+	created, err := storeShares(repo, secret, ss, plaintext, 0)
+	if err != nil {
+		return err
+	}
+
+	secret.Status = "ready"
+	if err := repo.Secret().Update(secret); err != nil {
+		revokeShares(repo, created)
+		return err
+	}
+
+	ss.Close()
+	return nil
+}
+
+// storeShares splits the plaintext and stores a share of the given
+// generation encrypted with each of the received passphrases. The secret's
+// digest and commitments are updated, but not stored. When it fails, the
+// shares it already stored are revoked.
+func storeShares(repo repository.Repository, secret *model.Secret, ss *SplitState, plaintext []byte, generation int) ([]model.Share, error) {
+	// Split the secret
+	shamirShares, err := splitSecret(secret, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	// Commit to the secret, so combines can verify what they recover
+	if secret.Digest, err = digestSecret(plaintext); err != nil {
+		return nil, err
+	}
+
+	// Encrypt and store the Shares
+	created := make([]model.Share, 0, len(shamirShares))
+	i := 0
+	for k, v := range shamirShares {
+		pp := ss.Passphrases[i]
+		i++
+
+		cipher, err := encryptShare(secret, pp, v)
+		if err != nil {
+			revokeShares(repo, created)
+			return nil, err
+		}
+
+		share, err := repo.Share().Create(&model.Share{
+			Secret:     secret.ID,
+			User:       pp.UserID,
+			Key:        k,
+			Share:      cipher,
+			Generation: generation,
+		})
+		if err != nil {
+			revokeShares(repo, created)
+			return nil, err
+		}
+		created = append(created, *share)
+	}
+
+	return created, nil
+}
+
+// failSplit tears down a split ceremony whose shares couldn't be stored and
+// marks the secret dead.
+func failSplit(repo repository.Repository, secret *model.Secret, ss *SplitState, err error) {
+	log.Error("Unable to split secret", "id", secret.ID, "error", err)
+
+	ss.Close()
+	secret.Status = "dead"
+	recordEvent(repo, secret.ID, secret.User, model.EventSplitFailed)
+	if err := repo.Secret().Update(secret); err != nil {
+		log.Error("Unable to mark secret dead", "id", secret.ID, "error", err)
+	}
+}
+
+// encryptShare encrypts a share with the passphrase, or to the public key,
+// its shareholder signed with.
+func encryptShare(secret *model.Secret, pp Passphrase, share []byte) ([]byte, error) {
+	if secret.Encryption == "ssh" {
+		return encryptToKey(share, pp.PublicKey)
+	}
+
+	return encrypt(share, pp.Passphrase)
+}
+
+// abortSplit tears down the split ceremony and marks the secret dead.
+func abortSplit(repo repository.Repository, secret *model.Secret, ss *SplitState) error {
+	ss.Close()
+	secret.Status = "dead"
+	recordEvent(repo, secret.ID, secret.User, model.EventSplitCancelled)
+	return repo.Secret().Update(secret)
+}
+
+// expireSplit tears down a split ceremony whose deadline passed and marks
+// the secret expired.
+func expireSplit(repo repository.Repository, secret *model.Secret, ss *SplitState) error {
+	log.Info("Split ceremony expired", "id", secret.ID, "signed", ss.Len(), "expected", ss.Expected)
+
+	ss.Close()
+	secret.Status = "expired"
+	recordEvent(repo, secret.ID, secret.User, model.EventSplitExpired)
+	return repo.Secret().Update(secret)
+}
+
 func RunSplitProgram(s ssh.Session, repo repository.Repository, cmd *cobra.Command) error {
 	pty, _, ok := s.Pty()
+	if !ok {
+		return errNoTerminal
+	}
 
 	parts, _ := cmd.Flags().GetInt("parts")
 	threshold, _ := cmd.Flags().GetInt("threshold")
+	shareholders, _ := cmd.Flags().GetStringSlice("shareholders")
+	recipients, _ := cmd.Flags().GetStringSlice("recipients")
+	encryption, _ := cmd.Flags().GetString("encryption")
+	multipleShares, _ := cmd.Flags().GetBool("multiple-shares")
+
+	shareholders, err := normalizeShareholders(repo, shareholders)
+	if err != nil {
+		return err
+	}
+	recipients, err = normalizeShareholders(repo, recipients)
+	if err != nil {
+		return err
+	}
 
 	splitTUI := SplitTUI{
-		TUI:       NewTUI(s),
-		repo:      repo,
-		progress:  progress.New(progress.WithWidth(pty.Window.Width-2), progress.WithoutPercentage()),
-		parts:     parts,
-		threshold: threshold,
+		TUI:          NewTUI(s),
+		repo:         repo,
+		progress:     progress.New(progress.WithWidth(pty.Window.Width-2), progress.WithoutPercentage()),
+		parts:        parts,
+		threshold:    threshold,
+		shareholders: shareholders,
+		names:        shareholderNames(repo, shareholders),
+		recipients:   recipients,
+		encryption:   encryption,
+		multiple:     multipleShares,
 	}
 
 	splitTUI.form = huh.NewForm(
@@ -80,27 +262,14 @@ func RunSplitProgram(s ssh.Session, repo repository.Repository, cmd *cobra.Comma
 				Key("confirm").
 				Title("Confirm Passphrase").
 				Description("Confirm your secure passphrase"),
-		),
+		).WithHide(encryption == "ssh"),
 	).
 		WithShowHelp(true).
 		WithSubmitCommand(submit).
 		WithCancelCommand(cancel).
 		WithTheme(huh.ThemeCatppuccin())
 
-	var p *tea.Program
-	if !ok || s.EmulatedPty() {
-		p = tea.NewProgram(splitTUI,
-			tea.WithInput(s),
-			tea.WithOutput(s),
-		)
-	} else {
-		p = tea.NewProgram(splitTUI,
-			tea.WithInput(pty.Slave),
-			tea.WithOutput(pty.Slave),
-		)
-	}
-
-	_, err := p.Run()
+	_, err = newProgram(s, splitTUI).Run()
 	return err
 }
 
@@ -110,11 +279,17 @@ type SplitTUI struct {
 	form     *huh.Form
 	progress progress.Model
 
-	parts     int
-	threshold int
+	parts        int
+	threshold    int
+	shareholders []string
+	names        map[string]string
+	recipients   []string
+	encryption   string
+	multiple     bool
 
 	secret     *model.Secret
 	splitState *SplitState
+	err        error
 }
 
 func (t SplitTUI) Init() tea.Cmd {
@@ -127,27 +302,22 @@ func (t SplitTUI) Init() tea.Cmd {
 func (t SplitTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case submitMsg:
-		s, err := t.repo.Secret().Create(&model.Secret{
-			User:      t.user.ID,
-			Label:     t.form.GetString("label"),
-			Parts:     t.parts,
-			Threshold: t.threshold,
-			Status:    "signing",
-			CreatedAt: time.Now(),
-		})
+		s, ss, err := startSplit(t.repo, t.user, &model.Secret{
+			Label:          t.form.GetString("label"),
+			Parts:          t.parts,
+			Threshold:      t.threshold,
+			Shareholders:   t.shareholders,
+			Recipients:     t.recipients,
+			Encryption:     t.encryption,
+			MultipleShares: t.multiple,
+		}, t.form.GetString("passphrase"))
 		if err != nil {
+			t.err = err
 			return t, tea.Quit
 		}
 
-		log.Info("Splitting a Secret", "id", s.ID, "user", t.user.ID)
-
 		t.secret = s
-		t.splitState = NewSplitState(t.secret.ID, t.secret.Parts)
-		t.splitState.Push(Passphrase{
-			UserID:     t.user.ID,
-			Username:   t.user.Username,
-			Passphrase: t.form.GetString("passphrase"),
-		})
+		t.splitState = ss
 
 		return t, receive(t.splitState)
 	case receiveMsg:
@@ -158,44 +328,24 @@ func (t SplitTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// Wait for another one
 		return t, receive(t.splitState)
+	case expiredMsg:
+		expireSplit(t.repo, t.secret, t.splitState)
+		return t, tea.Quit
+	case closedMsg:
+		t.secret.Status = "dead"
+		return t, tea.Quit
 	case receivedAllMsg:
-		// Split the secret
-		shamirShares, err := shamir.Split([]byte(t.form.GetString("secret")), t.secret.Parts, t.secret.Threshold)
-		if err != nil {
-			panic(err)
-		}
-
-		// Encrypt and store the Shares
-		i := 0
-		for k, v := range shamirShares {
-			pp := t.splitState.Passphrases[i]
-			i++
-
-			cipher, err := encrypt(v, pp.Passphrase)
-			if err != nil {
-				return t, tea.Quit
-			}
-
-			t.repo.Share().Create(&model.Share{
-				Secret: t.secret.ID,
-				User:   pp.UserID,
-				Key:    k,
-				Share:  cipher,
-			})
+		if err := finishSplit(t.repo, t.secret, t.splitState, []byte(t.form.GetString("secret"))); err != nil {
+			failSplit(t.repo, t.secret, t.splitState, err)
+			t.err = err
 		}
 
-		delete(SplitStates, t.secret.ID)
-		t.secret.Status = "ready"
-		t.repo.Secret().Update(t.secret)
-
 		return t, tea.Quit
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c":
 			if t.secret != nil && t.secret.Status == "signing" {
-				delete(SplitStates, t.secret.ID)
-				t.secret.Status = "dead"
-				t.repo.Secret().Update(t.secret)
+				abortSplit(t.repo, t.secret, t.splitState)
 			}
 		}
 	}
@@ -252,22 +402,32 @@ func (t SplitTUI) View() string {
 
 		if t.secret.Status == "signing" {
 			v.WriteString("Ask others to sign their shares with: ")
-			v.Colorf(lipgloss.Color("#0F0"), "ssh -t enge.me -- sign %s", t.secret.ID[8:])
+			v.Colorf(lipgloss.Color("#0F0"), "%s", signCommand(t.secret, false))
 			v.NL()
 			v.WriteString(t.progress.ViewAs(float64(t.splitState.Len()) / float64(t.splitState.Expected)))
+			v.NL()
+			writeRoster(v, t.splitState.Participants(), t.splitState.Expected, pendingShareholders(t.splitState, t.secret.Shareholders, t.names))
 		}
-		if t.secret.Status == "ready" {
-			v.WriteString("Retrieve your secret with: ")
-			v.Colorf(lipgloss.Color("#0F0"), "ssh -t enge.me -- combine %s", t.secret.ID[8:])
+		if t.secret.Status == "expired" {
+			v.Colorf(lipgloss.Color("#F00"), errCeremonyExpired.Error())
 			v.NL()
 		}
-
-		for _, pass := range t.splitState.Passphrases {
+		if t.secret.Status == "dead" {
+			err := errCeremonyClosed
+			if t.err != nil {
+				err = t.err
+			}
+			v.Colorf(lipgloss.Color("#F00"), err.Error())
 			v.NL()
-			v.Colorf(lipgloss.Color("#AFA"), "User: %s ", pass.Username)
-			v.Colorf(lipgloss.Color("#FAA"), "Length: %d", len(pass.Passphrase))
 		}
-
+		if t.secret.Status == "ready" {
+			v.WriteString("Retrieve your secret with: ")
+			v.Colorf(lipgloss.Color("#0F0"), "ssh -t enge.me -- combine %s", shortID(t.secret.ID))
+			v.NL()
+		}
+	} else if t.err != nil {
+		v.Colorf(lipgloss.Color("#F00"), t.err.Error())
+		v.NL()
 	} else {
 		v.WriteString(
 			t.form.View(),
@@ -9,7 +9,10 @@ import (
 )
 
 func RunSignProgram(s ssh.Session, repo repository.Repository, ss *SplitState) error {
-	pty, _, ok := s.Pty()
+	_, _, ok := s.Pty()
+	if !ok {
+		return errNoTerminal
+	}
 
 	signTUI := SignTUI{
 		TUI:        NewTUI(s),
@@ -32,20 +35,7 @@ func RunSignProgram(s ssh.Session, repo repository.Repository, ss *SplitState) e
 		WithCancelCommand(cancel).
 		WithTheme(huh.ThemeCatppuccin())
 
-	var p *tea.Program
-	if !ok || s.EmulatedPty() {
-		p = tea.NewProgram(signTUI,
-			tea.WithInput(s),
-			tea.WithOutput(s),
-		)
-	} else {
-		p = tea.NewProgram(signTUI,
-			tea.WithInput(pty.Slave),
-			tea.WithOutput(pty.Slave),
-		)
-	}
-
-	_, err := p.Run()
+	_, err := newProgram(s, signTUI).Run()
 	return err
 }
 
@@ -55,6 +45,7 @@ type SignTUI struct {
 
 	form       *huh.Form
 	splitState *SplitState
+	err        error
 }
 
 func (t SignTUI) Init() tea.Cmd {
@@ -64,9 +55,11 @@ func (t SignTUI) Init() tea.Cmd {
 func (t SignTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg.(type) {
 	case submitMsg:
-		t.splitState.Push(Passphrase{
+		t.err = t.splitState.Push(Passphrase{
 			UserID:     t.user.ID,
 			Username:   t.user.Username,
+			PublicKey:  t.user.PublicKey,
+			Keys:       t.user.Verified,
 			Passphrase: t.form.GetString("passphrase"),
 		})
 
@@ -92,7 +85,9 @@ func (t SignTUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 func (t SignTUI) View() string {
 	v := NewView()
 
-	if t.form.State == huh.StateCompleted {
+	if t.err != nil {
+		v.Colorf(lipgloss.Color("#F00"), "%s", t.err)
+	} else if t.form.State == huh.StateCompleted {
 		v.Colorf(lipgloss.Color("#0F0"), "You signed the secret!")
 	} else {
 		v.Colorf(lipgloss.Color("#0F0"), "You are signing the secret!")
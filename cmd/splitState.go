@@ -1,71 +1,204 @@
 package cmd
 
-import "sync"
-
-var SplitStates = make(map[string]*SplitState)
+import (
+	"sync"
+	"time"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/spf13/viper"
+)
+
+var SplitStates = newRegistry[*SplitState]()
+
+// NewSplitState opens a split ceremony expecting the given number of shares,
+// of which each user may sign up to perUser, or any number when it is 0.
+func NewSplitState(repo repository.Repository, secretId string, userId string, expected int, perUser int) (*SplitState, error) {
+	deadline := time.Now().Add(viper.GetDuration("split_timeout"))
+	ceremony, err := repo.Ceremony().Create(&model.Ceremony{
+		Secret:       secretId,
+		User:         userId,
+		Kind:         "split",
+		Expected:     expected,
+		Participants: make([]model.Participant, 0),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+		ExpiresAt:    deadline,
+	})
+	if err != nil {
+		return nil, err
+	}
 
-func NewSplitState(secretId string, expected int) *SplitState {
 	s := &SplitState{
+		repo:           repo,
+		ceremony:       ceremony,
 		SecretID:       secretId,
 		Expected:       expected,
+		PerUser:        perUser,
+		Deadline:       deadline,
 		Passphrases:    make([]Passphrase, 0),
+		signed:         map[string]int{},
 		chanPassphrase: make(chan Passphrase, expected),
-		chanDone:       make(chan error, 1),
+		chanClosed:     make(chan struct{}),
+	}
+
+	if !SplitStates.Add(secretId, s) {
+		repo.Ceremony().Delete(ceremony.ID)
+		return nil, errInCeremony
 	}
 
-	SplitStates[secretId] = s
-	return s
+	return s, nil
 }
 
 type SplitState struct {
 	mu             sync.Mutex
 	chanPassphrase chan Passphrase
-	chanDone       chan error
-
-	SecretID    string
-	Expected    int
+	chanClosed     chan struct{}
+
+	repo     repository.Repository
+	ceremony *model.Ceremony
+	closed   bool
+
+	// signed counts the passphrases pushed by each user, including the ones
+	// not received yet
+	signed map[string]int
+	pushed int
+
+	SecretID string
+	Expected int
+	PerUser  int
+	// Quotas, when set, limits each user to as many shares as they're
+	// given, refreshes rotating exactly the shares held
+	Quotas      map[string]int
+	Deadline    time.Time
 	Passphrases []Passphrase
 }
 
+// Passphrase is a signature of a split. PublicKey is the key the signer
+// connected with, and Keys are all of their verified keys.
 type Passphrase struct {
 	UserID     string
 	Username   string
+	PublicKey  string
+	Keys       []string
 	Passphrase string
 }
 
 func (s *SplitState) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	return len(s.Passphrases)
 }
 
-func (s *SplitState) Push(p Passphrase) {
+// Push hands a shareholder's passphrase to the ceremony. Signers beyond the
+// expected shares, or beyond their own share of them, are turned away.
+func (s *SplitState) Push(p Passphrase) error {
+	s.mu.Lock()
+	var err error
+	switch {
+	case s.closed:
+		err = errCeremonyClosed
+	case time.Now().After(s.Deadline):
+		err = errCeremonyExpired
+	case s.pushed >= s.Expected:
+		err = errCeremonyFull
+	case s.PerUser > 0 && s.signed[p.UserID] >= s.PerUser:
+		err = errAlreadySigned
+	case s.Quotas != nil && s.signed[p.UserID] >= s.Quotas[p.UserID]:
+		err = errAlreadyRotated
+	default:
+		s.signed[p.UserID]++
+		s.pushed++
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
 	s.chanPassphrase <- p
+	return nil
 }
 
-func (s *SplitState) Receive() {
-	go func() {
-		s.mu.Lock()
-		defer s.mu.Unlock()
+// ReceiveOne waits for the next signature, and returns who signed.
+func (s *SplitState) ReceiveOne() (model.Participant, error) {
+	var p Passphrase
+	select {
+	case p = <-s.chanPassphrase:
+	case <-s.chanClosed:
+		return model.Participant{}, errCeremonyClosed
+	case <-time.After(time.Until(s.Deadline)):
+		return model.Participant{}, errCeremonyExpired
+	}
 
-		for i := 0; i < s.Expected; i++ {
-			p := <-s.chanPassphrase
-			s.Passphrases = append(s.Passphrases, p)
-		}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Passphrases = append(s.Passphrases, p)
+
+	recordEvent(s.repo, s.SecretID, p.UserID, model.EventShareSigned)
 
-		s.chanDone <- nil
-	}()
+	// Only the participant is persisted, never the passphrase itself
+	participant := model.Participant{
+		User:     p.UserID,
+		Username: p.Username,
+		JoinedAt: time.Now(),
+	}
+	s.ceremony.Participants = append(s.ceremony.Participants, participant)
+	s.ceremony.UpdatedAt = time.Now()
+
+	return participant, s.repo.Ceremony().Update(s.ceremony)
 }
 
-func (s *SplitState) ReceiveOne() error {
-	p := <-s.chanPassphrase
+// Participants lists who signed so far, and when.
+func (s *SplitState) Participants() []model.Participant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]model.Participant{}, s.ceremony.Participants...)
+}
 
+// SignedBy counts the shares the user signed so far.
+func (s *SplitState) SignedBy(userId string) int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.Passphrases = append(s.Passphrases, p)
+	return s.signed[userId]
+}
+
+// Pending lists the designated shareholders that have yet to sign.
+func (s *SplitState) Pending(shareholders []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := []string{}
+	for _, id := range shareholders {
+		signed := false
+		for _, p := range s.Passphrases {
+			if isShareholder(id, model.User{ID: p.UserID, Username: p.Username, PublicKey: p.PublicKey, Verified: p.Keys}) {
+				signed = true
+				break
+			}
+		}
 
-	if len(s.Passphrases) == s.Expected {
-		s.chanDone <- nil
+		if !signed {
+			pending = append(pending, id)
+		}
 	}
 
-	return nil
+	return pending
+}
+
+// Close tears down the split state and its persisted ceremony.
+func (s *SplitState) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.chanClosed)
+	}
+	s.mu.Unlock()
+
+	SplitStates.Delete(s.SecretID, s)
+	return s.repo.Ceremony().Delete(s.ceremony.ID)
 }
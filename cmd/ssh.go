@@ -3,6 +3,8 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/adamgoose/ssss/lib"
 	"github.com/adamgoose/ssss/lib/model"
@@ -21,19 +23,29 @@ func NewSSHCmd(sess ssh.Session) *cobra.Command {
 Get started by creating an alias in your shell:
   $ alias sssc="ssh -t enge.me --"
 
+Browse your secrets, the shares you hold and your inbox, and launch
+ceremonies from the dashboard:
+  $ ssh enge.me
+
 Split your first secret:
   $ sssc split
   - Provide a label and your secret
   - Provide the first share encryption passphrase
+  - Optionally restrict who may sign with --shareholders alice,bob
+  - Optionally let others combine it with --recipients carol
+  - Optionally let one shareholder sign several shares with --multiple-shares
   - Share the provided "sign" command with your desired shareholders
   - The program exits when all shares are signed
 
+See the ceremonies waiting on you to sign or unsign:
+  $ sssc inbox
+
 Sign a secret being split:
   $ sssc sign {id}
   - Provide a passphrase to sign the share
   - The program exists after signing
 
-Combine shares to recover a secret:
+Combine shares to recover a secret you created, or were made a recipient of:
   $ sssc combine {id}
   - Share the provvided "unsign" command with your shareholders
   - The program exits when all shares are unsigned
@@ -42,7 +54,77 @@ Unsign a share:
   $ sssc unsign {id}
   - Provide the passphrase to unsign the share
   - The program exists after unsigning
+
+Reshare a secret with new shareholders, without revealing it:
+  $ sssc reshare {id} --parts 5 --threshold 3 --shareholders alice,bob,carol
+  - Share the provided "sign" command with the new shareholders
+  - Share the provided "unsign" command with the current shareholders
+  - The secret is split again once both are done, and the current shares
+    are revoked
+
+Refresh the shares of a long-lived secret, so leaked shares become useless:
+  $ sssc refresh {id}
+  - Every shareholder unsigns each of their current shares, and signs a
+    refreshed share in its place
+  - The secret is never recovered, and the current shares are revoked
+
+Manage the secrets you created:
+  $ sssc relabel {id} {label}
+  $ sssc archive {id}
+  - Archived secrets are hidden from list, unless you pass --all
+  $ sssc delete {id}
+  - Deletes the secret and every share of it
+
+Every ceremony can be scripted without a terminal by passing --stdin:
+  $ printf '%s\n%s' "$passphrase" "$secret" | ssh enge.me -- split --stdin -l label
+  $ echo "$passphrase" | ssh enge.me -- sign --stdin {id}
+  $ ssh enge.me -- combine --stdin {id}
+  $ echo "$passphrase" | ssh enge.me -- unsign --stdin {id}
+
+Shares can be encrypted to the shareholders' ssh-ed25519 keys instead of
+passphrases with --encryption ssh. Shareholders sign without a passphrase,
+and unsign by decrypting their shares locally with the ssss client:
+  $ sssc split --encryption ssh
+  $ ssh enge.me -- sign {id}
+  $ ssh enge.me -- unsign --export {id} | ssss unwrap | ssh enge.me -- unsign --stdin {id}
+
+Connect from another machine by adding its key from an existing session,
+then claiming it from that machine with the command it prints:
+  $ ssh enge.me -- keys add < ~/.ssh/id_ed25519.pub
+  $ ssh {username}@enge.me -- keys claim {token}
+  $ sssc keys ls
+  $ sssc keys rm {fingerprint}
+  - Your shares follow you to every key, but shares encrypted with
+    --encryption ssh can only be unsigned with the key that signed them
+
+See who you are connected as, your keys and the shares you hold:
+  $ sssc whoami
+
+Admins list the users, flagging shareholders who haven't connected lately,
+and approve the users waiting to be let in:
+  $ sssc admin users --inactive
+  $ sssc admin registrations
+  $ sssc admin approve {username|fingerprint}
+  $ sssc admin reject {username|fingerprint}
+
+Admins list every secret and running ceremony, kill stuck ceremonies and
+disable users. Every admin action is audited:
+  $ sssc admin secrets
+  $ sssc admin ceremonies
+  $ sssc admin kill {id}
+  $ sssc admin users disable {username|fingerprint}
+  $ sssc admin users enable {username|fingerprint}
+  $ sssc admin audit
 `,
+		Args: cobra.NoArgs,
+		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			// Without a terminal there is no dashboard to show
+			if _, _, ok := sess.Pty(); !ok {
+				return cmd.Help()
+			}
+
+			return RunDashboardProgram(sess, repo)
+		}),
 	}
 
 	lsCmd := &cobra.Command{
@@ -51,16 +133,53 @@ Unsign a share:
 		Short:   "Lists secrets.",
 		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
 			out := cmd.OutOrStdout()
-			secrets, err := repo.Secret().Mine(sess.Context().Value(model.User{}).(model.User).ID)
+			format, err := outputFormat(cmd)
 			if err != nil {
 				return err
 			}
 
+			secrets, err := repo.Secret().Mine(sessionUser(sess).ID)
+			if err != nil {
+				return err
+			}
+
+			// Archived secrets are hidden, unless asked for
+			if all, _ := cmd.Flags().GetBool("all"); !all {
+				current := secrets[:0]
+				for _, secret := range secrets {
+					if !secret.Archived {
+						current = append(current, secret)
+					}
+				}
+				secrets = current
+			}
+
+			sortSecrets(secrets)
+
+			outputs := make([]SecretOutput, 0, len(secrets))
 			for _, secret := range secrets {
-				fmt.Fprintf(out, "%s\t%d/%d\t%s\t%s\n", secret.ID[8:], secret.Threshold, secret.Parts, secret.Label, secret.CreatedAt.Format("2006-01-02 15:04:05"))
+				o, err := newSecretOutput(repo, &secret)
+				if err != nil {
+					return err
+				}
+				outputs = append(outputs, o)
 			}
 
-			return nil
+			if format != "table" {
+				return writeOutput(out, format, outputs)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tTHRESHOLD\tLABEL\tSTATUS\tCREATED\tSHAREHOLDERS")
+			for _, o := range outputs {
+				status := o.Status
+				if o.Archived {
+					status += " (archived)"
+				}
+				fmt.Fprintf(tw, "%s\t%d/%d\t%s\t%s\t%s\t%s\n", o.ID, o.Threshold, o.Parts, o.Label, status, o.CreatedAt.Format(timeFormat), strings.Join(o.Shareholders, ","))
+			}
+
+			return tw.Flush()
 		}),
 	}
 
@@ -68,11 +187,34 @@ Unsign a share:
 		Use:   "split",
 		Short: "Splits a secret into shares.",
 		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			// Verify the encryption, and that the initiator could sign with
+			// their own key
+			switch encryption, _ := cmd.Flags().GetString("encryption"); encryption {
+			case "passphrase":
+			case "ssh":
+				if _, err := x25519Recipient(sessionUser(sess).PublicKey); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("Unknown encryption %q, expected passphrase or ssh.", encryption)
+			}
+
+			// Verify the parts before the secret is created
+			parts, _ := cmd.Flags().GetInt("parts")
+			threshold, _ := cmd.Flags().GetInt("threshold")
+			if err := validateParts(parts, threshold); err != nil {
+				return err
+			}
+
 			ioc, _ := lib.Wrap(
 				di.ProvideValue(cmd),
 				di.ProvideValue(sess, di.As(new(ssh.Session))),
 			)
 
+			if stdin, _ := cmd.Flags().GetBool("stdin"); stdin {
+				return ioc.Invoke(RunSplitPlain)
+			}
+
 			return ioc.Invoke(RunSplitProgram)
 		}),
 	}
@@ -82,27 +224,39 @@ Unsign a share:
 		Short: "Signs a share with a passphrase.",
 		Args:  cobra.ExactArgs(1),
 		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
-			// Lookup the secret by ID
-			secret, err := repo.Secret().Get(args[0])
+			// Lookup the secret, which only designated shareholders may sign
+			user := sessionUser(sess)
+			secret, err := authorizedSecret(repo, user, args[0], actionSign)
 			if err != nil {
 				return err
 			}
 
 			// Verify it's in a signing state
-			if secret.Status != "signing" {
+			if secret.Status != "signing" && secret.Status != "resharing" && secret.Status != "refreshing" {
 				return errors.New("Secret is not in a signing state.")
 			}
 
-			splitState, ok := SplitStates[secret.ID]
+			splitState, ok := SplitStates.Get(secret.ID)
 			if !ok {
 				return errors.New("Secret is not in a signing state.")
 			}
 
 			ioc, _ := lib.Wrap(
+				di.ProvideValue(cmd),
+				di.ProvideValue(secret),
 				di.ProvideValue(splitState),
 				di.ProvideValue(sess, di.As(new(ssh.Session))),
 			)
 
+			// Shares encrypted to SSH keys are signed with the session's key
+			if secret.Encryption == "ssh" {
+				return ioc.Invoke(RunSignKey)
+			}
+
+			if stdin, _ := cmd.Flags().GetBool("stdin"); stdin {
+				return ioc.Invoke(RunSignPlain)
+			}
+
 			return ioc.Invoke(RunSignProgram)
 		}),
 	}
@@ -112,24 +266,38 @@ Unsign a share:
 		Short: "Combines shares to recover a secret.",
 		Args:  cobra.ExactArgs(1),
 		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
-			// Lookup the secret by ID
-			secret, err := repo.Secret().Get(args[0])
+			// Lookup the secret, which only its creator and recipients may
+			// combine
+			user := sessionUser(sess)
+			secret, err := authorizedSecret(repo, user, args[0], actionCombine)
 			if err != nil {
 				return err
 			}
 
-			// Verify it's in a signing state
+			// Verify it's in a ready state, and not being combined already
 			if secret.Status != "ready" {
 				return errors.New("Secret is not in a ready state.")
 			}
+			if inCeremony(secret) {
+				return errInCeremony
+			}
 
-			cs := NewCombineState(secret.ID, secret.Threshold)
+			cs, err := NewCombineState(repo, secret, user.ID, secret.Threshold, sharesPerUser(secret))
+			if err != nil {
+				return err
+			}
 
 			ioc, _ := lib.Wrap(
+				di.ProvideValue(cmd),
+				di.ProvideValue(secret),
 				di.ProvideValue(cs),
 				di.ProvideValue(sess, di.As(new(ssh.Session))),
 			)
 
+			if stdin, _ := cmd.Flags().GetBool("stdin"); stdin {
+				return ioc.Invoke(RunCombinePlain)
+			}
+
 			return ioc.Invoke(RunCombineProgram)
 		}),
 	}
@@ -139,46 +307,393 @@ Unsign a share:
 		Short: "Unsigns a share with a passphrase.",
 		Args:  cobra.ExactArgs(1),
 		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
-			// Lookup the secret by ID
-			secret, err := repo.Secret().Get(args[0])
+			// Lookup the secret, which only its shareholders may unsign
+			user := sessionUser(sess)
+			secret, err := authorizedSecret(repo, user, args[0], actionUnsign)
 			if err != nil {
 				return err
 			}
 
-			// Verify it's in a signing state
-			if secret.Status != "ready" {
+			// Verify it's being combined, reshared or refreshed
+			if secret.Status != "ready" && secret.Status != "resharing" && secret.Status != "refreshing" {
 				return errors.New("Secret is not in a ready state.")
 			}
 
-			cs, ok := CombineStates[secret.ID]
+			cs, ok := CombineStates.Get(secret.ID)
 			if !ok {
 				return errors.New("Secret is not being combined.")
 			}
 
 			// Load the shares
-			shares, err := repo.Share().MineForSecret(secret.ID, sess.Context().Value(model.User{}).(model.User).ID)
+			shares, err := repo.Share().MineForSecret(secret.ID, user.ID)
 			if err != nil {
 				return err
 			}
 
 			ioc, err := lib.Wrap(
+				di.ProvideValue(cmd),
+				di.ProvideValue(secret),
 				di.ProvideValue(cs),
 				di.ProvideValue(shares),
 				di.ProvideValue(sess, di.As(new(ssh.Session))),
 			)
 
+			// Shares encrypted to SSH keys are decrypted client side
+			stdin, _ := cmd.Flags().GetBool("stdin")
+			if secret.Encryption == "ssh" {
+				if export, _ := cmd.Flags().GetBool("export"); export {
+					return ioc.Invoke(RunExportShares)
+				}
+				if stdin {
+					return ioc.Invoke(RunUnsignKey)
+				}
+
+				return fmt.Errorf("Shares of this secret are encrypted to your SSH key, unsign them with: %s", unsignCommand(secret, true))
+			}
+
+			if stdin {
+				return ioc.Invoke(RunUnsignPlain)
+			}
+
 			return ioc.Invoke(RunUnsignProgram)
 		}),
 	}
 
+	reshareCmd := &cobra.Command{
+		Use:   "reshare {id}",
+		Short: "Reshares a secret with new shareholders.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			// Lookup the secret, which only its creator may reshare
+			user := sessionUser(sess)
+			secret, err := authorizedSecret(repo, user, args[0], actionReshare)
+			if err != nil {
+				return err
+			}
+
+			// Verify it's in a ready state, and not being combined
+			if secret.Status != "ready" {
+				return errors.New("Secret is not in a ready state.")
+			}
+			if inCeremony(secret) {
+				return errInCeremony
+			}
+
+			// The new split keeps anything that isn't changed
+			target := *secret
+			if cmd.Flags().Changed("parts") {
+				target.Parts, _ = cmd.Flags().GetInt("parts")
+			}
+			if cmd.Flags().Changed("threshold") {
+				target.Threshold, _ = cmd.Flags().GetInt("threshold")
+			}
+			if cmd.Flags().Changed("shareholders") {
+				shareholders, _ := cmd.Flags().GetStringSlice("shareholders")
+				if target.Shareholders, err = normalizeShareholders(repo, shareholders); err != nil {
+					return err
+				}
+			} else if target.Shareholders, err = resolveShareholders(repo, secret, secret.Shareholders); err != nil {
+				return err
+			}
+			if err := validateParts(target.Parts, target.Threshold); err != nil {
+				return err
+			}
+
+			rs, err := startReshare(repo, user, secret, &target, false)
+			if err != nil {
+				return err
+			}
+
+			ioc, _ := lib.Wrap(
+				di.ProvideValue(cmd),
+				di.ProvideValue(secret),
+				di.ProvideValue(rs),
+				di.ProvideValue(sess, di.As(new(ssh.Session))),
+			)
+
+			if stdin, _ := cmd.Flags().GetBool("stdin"); stdin {
+				return ioc.Invoke(RunResharePlain)
+			}
+
+			return ioc.Invoke(RunReshareProgram)
+		}),
+	}
+
+	refreshCmd := &cobra.Command{
+		Use:   "refresh {id}",
+		Short: "Rotates every share of a secret, without revealing it.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			// Lookup the secret, which only its creator may refresh
+			user := sessionUser(sess)
+			secret, err := authorizedSecret(repo, user, args[0], actionRefresh)
+			if err != nil {
+				return err
+			}
+
+			// Verify it's in a ready state, and not being combined
+			if secret.Status != "ready" {
+				return errors.New("Secret is not in a ready state.")
+			}
+			if inCeremony(secret) {
+				return errInCeremony
+			}
+
+			if secret.Scheme != schemePedersen {
+				return errors.New("Shares of this secret can't be refreshed, reshare it instead.")
+			}
+
+			// The refreshed shares go to the current shareholders
+			shares, err := repo.Share().ForSecret(secret.ID)
+			if err != nil {
+				return err
+			}
+
+			target := *secret
+			target.Shareholders = []string{}
+			seen := map[string]bool{}
+			for _, share := range shares {
+				if seen[share.User] {
+					continue
+				}
+				seen[share.User] = true
+
+				target.Shareholders = append(target.Shareholders, share.User)
+			}
+
+			rs, err := startReshare(repo, user, secret, &target, true)
+			if err != nil {
+				return err
+			}
+
+			ioc, _ := lib.Wrap(
+				di.ProvideValue(cmd),
+				di.ProvideValue(secret),
+				di.ProvideValue(rs),
+				di.ProvideValue(sess, di.As(new(ssh.Session))),
+			)
+
+			if stdin, _ := cmd.Flags().GetBool("stdin"); stdin {
+				return ioc.Invoke(RunResharePlain)
+			}
+
+			return ioc.Invoke(RunReshareProgram)
+		}),
+	}
+
+	deleteCmd := &cobra.Command{
+		Use:   "delete {id}",
+		Short: "Deletes a secret and its shares.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			secret, err := authorizedSecret(repo, sessionUser(sess), args[0], actionDelete)
+			if err != nil {
+				return err
+			}
+
+			if inCeremony(secret) {
+				return errInCeremony
+			}
+
+			if err := repo.Secret().Delete(secret.ID); err != nil {
+				return err
+			}
+
+			recordEvent(repo, secret.ID, secret.User, model.EventSecretDeleted)
+			fmt.Fprintf(cmd.ErrOrStderr(), "Deleted secret %s and its shares.\n", shortID(secret.ID))
+			return nil
+		}),
+	}
+
+	archiveCmd := &cobra.Command{
+		Use:   "archive {id}",
+		Short: "Archives a secret, hiding it from list.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			secret, err := authorizedSecret(repo, sessionUser(sess), args[0], actionArchive)
+			if err != nil {
+				return err
+			}
+
+			if inCeremony(secret) {
+				return errInCeremony
+			}
+
+			undo, _ := cmd.Flags().GetBool("undo")
+			secret.Archived = !undo
+			if err := repo.Secret().Update(secret); err != nil {
+				return err
+			}
+
+			if undo {
+				recordEvent(repo, secret.ID, secret.User, model.EventSecretUnarchived)
+			} else {
+				recordEvent(repo, secret.ID, secret.User, model.EventSecretArchived)
+			}
+
+			if format != "table" {
+				return writeSecret(cmd.OutOrStdout(), format, repo, secret)
+			}
+
+			if undo {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Restored secret %s.\n", shortID(secret.ID))
+			} else {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Archived secret %s.\n", shortID(secret.ID))
+			}
+			return nil
+		}),
+	}
+
+	relabelCmd := &cobra.Command{
+		Use:   "relabel {id} {label}",
+		Short: "Changes the label of a secret.",
+		Args:  cobra.ExactArgs(2),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			secret, err := authorizedSecret(repo, sessionUser(sess), args[0], actionRelabel)
+			if err != nil {
+				return err
+			}
+
+			if inCeremony(secret) {
+				return errInCeremony
+			}
+
+			secret.Label = args[1]
+			if err := repo.Secret().Update(secret); err != nil {
+				return err
+			}
+
+			recordEvent(repo, secret.ID, secret.User, model.EventSecretRelabeled)
+
+			if format != "table" {
+				return writeSecret(cmd.OutOrStdout(), format, repo, secret)
+			}
+
+			fmt.Fprintf(cmd.ErrOrStderr(), "Relabeled secret %s.\n", shortID(secret.ID))
+			return nil
+		}),
+	}
+
+	rootCmd.PersistentFlags().StringP("output", "o", "table", "Output format for list, inbox, audit, whoami, keys, admin listings and --stdin ceremonies: table, json or yaml.")
+
+	auditCmd := &cobra.Command{
+		Use:   "audit {id}",
+		Short: "Shows the audit log of a secret.",
+		Args:  cobra.ExactArgs(1),
+		RunE: lib.RunE(func(cmd *cobra.Command, args []string, repo repository.Repository) error {
+			out := cmd.OutOrStdout()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			// Lookup the secret, which only its creator, recipients and
+			// shareholders may audit
+			secret, err := authorizedSecret(repo, sessionUser(sess), args[0], actionAudit)
+			if err != nil {
+				return err
+			}
+
+			events, err := repo.Audit().ForSecret(secret.ID)
+			if err != nil {
+				return err
+			}
+
+			outputs, err := newEventOutputs(repo, events)
+			if err != nil {
+				return err
+			}
+
+			if format != "table" {
+				return writeOutput(out, format, outputs)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "TIME\tACTION\tUSER")
+			for _, o := range outputs {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", o.CreatedAt.Format(timeFormat), o.Action, o.User)
+			}
+
+			return tw.Flush()
+		}),
+	}
+
+	inboxCmd := &cobra.Command{
+		Use:     "inbox",
+		Aliases: []string{"todo"},
+		Short:   "Lists the ceremonies waiting on you to sign or unsign.",
+		Args:    cobra.NoArgs,
+		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			out := cmd.OutOrStdout()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			outputs, err := inbox(repo, sessionUser(sess), snapshotCeremonies())
+			if err != nil {
+				return err
+			}
+
+			if format != "table" {
+				return writeOutput(out, format, outputs)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tLABEL\tCEREMONY\tACTION\tREMAINING\tINITIATOR\tEXPIRES\tCOMMAND")
+			for _, o := range outputs {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", o.ID, o.Label, o.Ceremony, o.Action, o.Remaining, o.Initiator, o.ExpiresAt.Format(timeFormat), o.Command)
+			}
+
+			return tw.Flush()
+		}),
+	}
+
 	splitCmd.Flags().IntP("parts", "p", 3, "How many shares to split the secret into.")
 	splitCmd.Flags().IntP("threshold", "t", 2, "How many shares are required to reconstruct the secret.")
+	splitCmd.Flags().StringSliceP("shareholders", "s", nil, "Usernames or public keys of the shareholders allowed to sign.")
+	splitCmd.Flags().StringSliceP("recipients", "r", nil, "Usernames or public keys of the users allowed to combine the secret, besides you.")
+	splitCmd.Flags().Bool("multiple-shares", false, "Allow one shareholder to sign, and later unsign, more than one share.")
+	splitCmd.Flags().StringP("label", "l", "", "An insecure label for your secret, when using --stdin.")
+	splitCmd.Flags().Bool("stdin", false, "Read the passphrase and then the secret from stdin instead of running the TUI.")
+	splitCmd.Flags().StringP("encryption", "e", "passphrase", "Encrypt shares with a passphrase, or to the shareholders' ssh-ed25519 keys: passphrase or ssh.")
+	signCmd.Flags().Bool("stdin", false, "Read the passphrase from stdin instead of running the TUI.")
+	combineCmd.Flags().Bool("stdin", false, "Print the secret instead of running the TUI.")
+	unsignCmd.Flags().Bool("stdin", false, "Read the passphrase from stdin instead of running the TUI.")
+	reshareCmd.Flags().IntP("parts", "p", 3, "How many shares to split the secret into, defaults to the current parts.")
+	reshareCmd.Flags().IntP("threshold", "t", 2, "How many shares are required to reconstruct the secret, defaults to the current threshold.")
+	reshareCmd.Flags().StringSliceP("shareholders", "s", nil, "Usernames or public keys of the new shareholders, defaults to the current ones.")
+	reshareCmd.Flags().Bool("stdin", false, "Report progress on stderr instead of running the TUI.")
+	lsCmd.Flags().BoolP("all", "a", false, "Include archived secrets.")
+	archiveCmd.Flags().Bool("undo", false, "Restore an archived secret.")
+	refreshCmd.Flags().Bool("stdin", false, "Report progress on stderr instead of running the TUI.")
+	unsignCmd.Flags().Bool("export", false, "Print your shares encrypted to your SSH key, for \"ssss unwrap\".")
 
 	rootCmd.AddCommand(lsCmd)
 	rootCmd.AddCommand(splitCmd)
 	rootCmd.AddCommand(signCmd)
 	rootCmd.AddCommand(combineCmd)
 	rootCmd.AddCommand(unsignCmd)
+	rootCmd.AddCommand(reshareCmd)
+	rootCmd.AddCommand(refreshCmd)
+	rootCmd.AddCommand(deleteCmd)
+	rootCmd.AddCommand(archiveCmd)
+	rootCmd.AddCommand(relabelCmd)
+	rootCmd.AddCommand(auditCmd)
+	rootCmd.AddCommand(inboxCmd)
+	rootCmd.AddCommand(newWhoamiCmd(sess))
+	rootCmd.AddCommand(newKeysCmd(sess))
+	rootCmd.AddCommand(newAdminCmd(sess))
 
 	return rootCmd
 }
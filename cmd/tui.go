@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"errors"
+
 	"github.com/adamgoose/ssss/lib/model"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -8,6 +10,8 @@ import (
 	"github.com/charmbracelet/wish/bubbletea"
 )
 
+var errNoTerminal = errors.New("This command requires an active terminal. Connect with ssh -t, or pass --stdin.")
+
 type TUI struct {
 	term     string
 	width    int
@@ -25,11 +29,27 @@ func NewTUI(s ssh.Session) TUI {
 		width:    pty.Window.Width,
 		height:   pty.Window.Height,
 		session:  s,
-		user:     s.Context().Value(model.User{}).(model.User),
+		user:     sessionUser(s),
 		renderer: bubbletea.MakeRenderer(s),
 	}
 }
 
+// newProgram runs the model on the session's terminal.
+func newProgram(s ssh.Session, model tea.Model, opts ...tea.ProgramOption) *tea.Program {
+	pty, _, _ := s.Pty()
+
+	switch piped, ok := s.(pipedSession); {
+	case !s.EmulatedPty():
+		opts = append(opts, tea.WithInput(pty.Slave), tea.WithOutput(pty.Slave))
+	case ok:
+		opts = append(opts, tea.WithInput(piped.input), tea.WithOutput(s))
+	default:
+		opts = append(opts, tea.WithInput(s), tea.WithOutput(s))
+	}
+
+	return tea.NewProgram(model, opts...)
+}
+
 func (t TUI) Init() tea.Cmd {
 	return nil
 }
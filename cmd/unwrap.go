@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// NewUnwrapCmd is the client side of shares encrypted to SSH keys. It runs on
+// the shareholder's machine, so their private key never reaches the server.
+func NewUnwrapCmd() *cobra.Command {
+	unwrapCmd := &cobra.Command{
+		Use:   "unwrap",
+		Short: "Decrypts shares encrypted to your SSH key.",
+		Long: `Decrypts shares encrypted to your SSH key.
+
+Reads the output of "unsign --export" on stdin, and prints the decrypted
+shares for "unsign --stdin":
+  $ ssh enge.me -- unsign --export {id} | ssss unwrap | ssh enge.me -- unsign --stdin {id}
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			identity, _ := cmd.Flags().GetString("identity")
+			key, err := loadIdentity(identity)
+			if err != nil {
+				return err
+			}
+
+			unwrapped := 0
+			scanner := bufio.NewScanner(cmd.InOrStdin())
+			for scanner.Scan() {
+				id, data, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+				if !ok {
+					continue
+				}
+
+				enctext, err := base64.StdEncoding.DecodeString(data)
+				if err != nil {
+					return err
+				}
+
+				// Skip shares encrypted to the user's other keys
+				plaintext, err := decryptWithKey(enctext, key)
+				if err != nil {
+					continue
+				}
+
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s\n", id, base64.StdEncoding.EncodeToString(plaintext))
+				unwrapped++
+			}
+			if err := scanner.Err(); err != nil {
+				return err
+			}
+
+			if unwrapped == 0 {
+				return errors.New("None of the shares could be decrypted with that key.")
+			}
+
+			return nil
+		},
+	}
+
+	home, _ := os.UserHomeDir()
+	unwrapCmd.Flags().StringP("identity", "i", filepath.Join(home, ".ssh", "id_ed25519"), "The ssh-ed25519 private key to decrypt shares with.")
+
+	return unwrapCmd
+}
+
+// loadIdentity reads an ssh-ed25519 private key, prompting on the terminal for
+// its passphrase when it is encrypted. Stdin carries the shares, so the
+// prompt goes through /dev/tty.
+func loadIdentity(path string) (ed25519.PrivateKey, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := gossh.ParseRawPrivateKey(pem)
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer tty.Close()
+
+		fmt.Fprintf(tty, "Enter passphrase for %s: ", path)
+		passphrase, err := term.ReadPassword(int(tty.Fd()))
+		fmt.Fprintln(tty)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err = gossh.ParseRawPrivateKeyWithPassphrase(pem, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	switch key := raw.(type) {
+	case ed25519.PrivateKey:
+		return key, nil
+	case *ed25519.PrivateKey:
+		return *key, nil
+	}
+
+	return nil, errUnsupportedKey
+}
@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/charmbracelet/ssh"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// UserOutput is the machine-readable representation of a user. Users holding
+// shares are inactive once they haven't connected for SSSS_INACTIVE_AFTER,
+// their shares may be lost with them.
+type UserOutput struct {
+	Username  string    `json:"username" yaml:"username"`
+	Status    string    `json:"status" yaml:"status"`
+	Keys      []string  `json:"keys" yaml:"keys"`
+	FirstSeen time.Time `json:"first_seen" yaml:"first_seen"`
+	LastSeen  time.Time `json:"last_seen" yaml:"last_seen"`
+	Shares    int       `json:"shares" yaml:"shares"`
+	Inactive  bool      `json:"inactive" yaml:"inactive"`
+}
+
+// HeldOutput is the machine-readable representation of the shares a user
+// holds of a secret.
+type HeldOutput struct {
+	ID     string `json:"id" yaml:"id"`
+	Label  string `json:"label" yaml:"label"`
+	Shares int    `json:"shares" yaml:"shares"`
+}
+
+// WhoamiOutput is the machine-readable representation of the user of a
+// session.
+type WhoamiOutput struct {
+	UserOutput `yaml:",inline"`
+	Key        string       `json:"key" yaml:"key"`
+	Admin      bool         `json:"admin" yaml:"admin"`
+	Held       []HeldOutput `json:"held" yaml:"held"`
+}
+
+func newUserOutput(repo repository.Repository, user model.User) (UserOutput, error) {
+	held, err := heldShares(repo, user)
+	if err != nil {
+		return UserOutput{}, err
+	}
+
+	return userOutput(user, held), nil
+}
+
+func userOutput(user model.User, held []HeldOutput) UserOutput {
+	status := user.Status
+	if status == "" {
+		status = userActive
+	}
+
+	out := UserOutput{
+		Username:  user.Username,
+		Status:    status,
+		Keys:      []string{},
+		FirstSeen: user.FirstSeen,
+		LastSeen:  user.LastSeen,
+	}
+	for _, key := range userKeys(user) {
+		out.Keys = append(out.Keys, displayShareholder(key))
+	}
+	for _, h := range held {
+		out.Shares += h.Shares
+	}
+	out.Inactive = out.Shares > 0 && time.Since(user.LastSeen) > viper.GetDuration("inactive_after")
+
+	return out
+}
+
+// heldShares lists the current shares the user holds, by secret.
+func heldShares(repo repository.Repository, user model.User) ([]HeldOutput, error) {
+	secrets, err := repo.Secret().Held(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	sortSecrets(secrets)
+
+	held := make([]HeldOutput, 0, len(secrets))
+	for _, secret := range secrets {
+		shares, err := repo.Share().MineForSecret(secret.ID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		held = append(held, HeldOutput{
+			ID:     shortID(secret.ID),
+			Label:  secret.Label,
+			Shares: len(shares),
+		})
+	}
+
+	return held, nil
+}
+
+// seen formats when a user was seen, which is unknown for users registered
+// before it was tracked.
+func seen(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+
+	return t.Format(timeFormat)
+}
+
+func newWhoamiCmd(sess ssh.Session) *cobra.Command {
+	return &cobra.Command{
+		Use:   "whoami",
+		Short: "Shows who you are connected as, your keys and the shares you hold.",
+		Args:  cobra.NoArgs,
+		RunE: lib.RunE(func(cmd *cobra.Command, repo repository.Repository) error {
+			out := cmd.OutOrStdout()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+
+			session := sessionUser(sess)
+			user, err := repo.User().Get(session.ID)
+			if err != nil {
+				return err
+			}
+
+			held, err := heldShares(repo, *user)
+			if err != nil {
+				return err
+			}
+
+			o := WhoamiOutput{
+				UserOutput: userOutput(*user, held),
+				Key:        displayShareholder(session.PublicKey),
+				Admin:      isAdmin(session.PublicKey),
+				Held:       held,
+			}
+
+			if format != "table" {
+				return writeOutput(out, format, o)
+			}
+
+			tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintf(tw, "Username\t%s\n", o.Username)
+			fmt.Fprintf(tw, "Status\t%s\n", o.Status)
+			if o.Admin {
+				fmt.Fprintf(tw, "Role\tadmin\n")
+			}
+			fmt.Fprintf(tw, "Connected with\t%s\n", o.Key)
+			fmt.Fprintf(tw, "Keys\t%s\n", strings.Join(o.Keys, ", "))
+			fmt.Fprintf(tw, "First seen\t%s\n", seen(o.FirstSeen))
+			fmt.Fprintf(tw, "Last seen\t%s\n", seen(o.LastSeen))
+			fmt.Fprintf(tw, "Shares\t%d of %d secrets\n", o.Shares, len(o.Held))
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+
+			if len(o.Held) == 0 {
+				return nil
+			}
+
+			fmt.Fprintln(out)
+			tw = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tLABEL\tSHARES")
+			for _, h := range o.Held {
+				fmt.Fprintf(tw, "%s\t%s\t%d\n", h.ID, h.Label, h.Shares)
+			}
+
+			return tw.Flush()
+		}),
+	}
+}
@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"errors"
+	"io"
+
+	"filippo.io/edwards25519"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/corvus-ch/shamir"
+)
+
+// Secrets are split with one of two schemes. The original "shamir" scheme
+// splits every byte of the secret over GF(256), and its shares can't be
+// verified. The "pedersen" scheme is Pedersen's verifiable secret sharing
+// over the edwards25519 scalar field: the secret is cut into 31 byte chunks,
+// each chunk is shared with its own pair of random polynomials, and the
+// coefficients are committed to as a*G + b*H. Every share can be checked
+// against those commitments on its own, without learning anything about the
+// secret, so a corrupted share is caught before it spoils a combine.
+const (
+	schemeShamir   = "shamir"
+	schemePedersen = "pedersen"
+)
+
+// pedersenChunkSize keeps every chunk below the order of the group, so it
+// always fits a canonical scalar.
+const pedersenChunkSize = 31
+
+var errInvalidShare = errors.New("share does not match the secret's commitments")
+
+// pedersenH is the second generator of the commitments. It is derived by
+// hashing to the curve, so nobody knows its discrete log with respect to G.
+var pedersenH = func() *edwards25519.Point {
+	for i := byte(0); ; i++ {
+		h := sha512.Sum512(append([]byte("ssss/pedersen/H"), i))
+		p, err := new(edwards25519.Point).SetBytes(h[:32])
+		if err != nil {
+			continue
+		}
+
+		p.MultByCofactor(p)
+		if p.Equal(edwards25519.NewIdentityPoint()) == 1 {
+			continue
+		}
+
+		return p
+	}
+}()
+
+// splitSecret splits the plaintext into a share for each of the secret's
+// parts, keyed by their x coordinate. Pedersen commitments are stored on the
+// secret.
+func splitSecret(secret *model.Secret, plaintext []byte) (map[byte][]byte, error) {
+	if secret.Scheme != schemePedersen {
+		return shamir.Split(plaintext, secret.Parts, secret.Threshold)
+	}
+
+	if err := validateParts(secret.Parts, secret.Threshold); err != nil {
+		return nil, err
+	}
+
+	// Pad the plaintext to a whole number of chunks
+	padded := append(append([]byte{}, plaintext...), 0x80)
+	for len(padded)%pedersenChunkSize != 0 {
+		padded = append(padded, 0)
+	}
+
+	shares := make(map[byte][]byte, secret.Parts)
+	commitments := make([]byte, 0, len(padded)/pedersenChunkSize*secret.Threshold*32)
+	for c := 0; c < len(padded); c += pedersenChunkSize {
+		// Random polynomials f and g, where f(0) is the chunk
+		f := make([]*edwards25519.Scalar, secret.Threshold)
+		g := make([]*edwards25519.Scalar, secret.Threshold)
+		for j := range f {
+			var err error
+			if f[j], err = randomScalar(); err != nil {
+				return nil, err
+			}
+			if g[j], err = randomScalar(); err != nil {
+				return nil, err
+			}
+		}
+
+		chunk := make([]byte, 32)
+		copy(chunk, padded[c:c+pedersenChunkSize])
+		if _, err := f[0].SetCanonicalBytes(chunk); err != nil {
+			return nil, err
+		}
+
+		for j := range f {
+			commitments = append(commitments, commit(f[j], g[j]).Bytes()...)
+		}
+
+		for x := 1; x <= secret.Parts; x++ {
+			shares[byte(x)] = append(shares[byte(x)], evaluate(f, byte(x)).Bytes()...)
+			shares[byte(x)] = append(shares[byte(x)], evaluate(g, byte(x)).Bytes()...)
+		}
+	}
+
+	secret.Commitments = commitments
+	return shares, nil
+}
+
+// combineSecret recovers the plaintext from shares keyed by their x
+// coordinate.
+func combineSecret(secret *model.Secret, shares map[byte][]byte) ([]byte, error) {
+	if secret.Scheme != schemePedersen {
+		return shamir.Combine(shares)
+	}
+
+	xs := make([]byte, 0, len(shares))
+	size := -1
+	for x, share := range shares {
+		if size != -1 && len(share) != size || len(share)%64 != 0 {
+			return nil, errInvalidShare
+		}
+		size = len(share)
+		xs = append(xs, x)
+	}
+	if len(xs) == 0 {
+		return nil, errors.New("no shares to combine")
+	}
+	if len(xs) < secret.Threshold {
+		return nil, errors.New("fewer shares than the threshold")
+	}
+
+	// Interpolate f(0) of each chunk
+	padded := make([]byte, 0, size/64*pedersenChunkSize)
+	for c := 0; c < size; c += 64 {
+		chunk := edwards25519.NewScalar()
+		for _, x := range xs {
+			s, err := edwards25519.NewScalar().SetCanonicalBytes(shares[x][c : c+32])
+			if err != nil {
+				return nil, errInvalidShare
+			}
+
+			chunk.Add(chunk, s.Multiply(s, lagrange(xs, x)))
+		}
+
+		b := chunk.Bytes()
+		if b[pedersenChunkSize] != 0 {
+			return nil, errInvalidShare
+		}
+		padded = append(padded, b[:pedersenChunkSize]...)
+	}
+
+	// Strip the padding
+	end := bytes.LastIndexByte(padded, 0x80)
+	if end == -1 || len(bytes.Trim(padded[end+1:], "\x00")) != 0 {
+		return nil, errInvalidShare
+	}
+
+	return padded[:end], nil
+}
+
+// verifyShare checks a share against the secret's commitments. Shares of the
+// shamir scheme can't be verified and are always accepted.
+func verifyShare(secret *model.Secret, x byte, share []byte) error {
+	if secret.Scheme != schemePedersen {
+		return nil
+	}
+
+	t := secret.Threshold
+	if x == 0 || t < 1 || len(share)%64 != 0 || len(share)/64*t*32 != len(secret.Commitments) {
+		return errInvalidShare
+	}
+
+	for c := 0; c < len(share)/64; c++ {
+		s, err := edwards25519.NewScalar().SetCanonicalBytes(share[c*64 : c*64+32])
+		if err != nil {
+			return errInvalidShare
+		}
+		r, err := edwards25519.NewScalar().SetCanonicalBytes(share[c*64+32 : c*64+64])
+		if err != nil {
+			return errInvalidShare
+		}
+
+		// s*G + r*H must equal the sum of C_j * x^j
+		expected := edwards25519.NewIdentityPoint()
+		power := scalarFromByte(1)
+		for j := 0; j < t; j++ {
+			offset := (c*t + j) * 32
+			cj, err := new(edwards25519.Point).SetBytes(secret.Commitments[offset : offset+32])
+			if err != nil {
+				return errInvalidShare
+			}
+
+			expected.Add(expected, new(edwards25519.Point).ScalarMult(power, cj))
+			power.Multiply(power, scalarFromByte(x))
+		}
+
+		if commit(s, r).Equal(expected) != 1 {
+			return errInvalidShare
+		}
+	}
+
+	return nil
+}
+
+// refreshShares rotates every share of a pedersen secret without recovering
+// it, by adding a random polynomial whose constant term is zero. The secret
+// is unchanged, but the refreshed shares can't be combined with the old
+// ones. The commitments of the refreshed shares are returned along with
+// them.
+func refreshShares(secret *model.Secret, shares map[byte][]byte) (map[byte][]byte, []byte, error) {
+	if secret.Scheme != schemePedersen {
+		return nil, nil, errors.New("only shares of the pedersen scheme can be refreshed")
+	}
+
+	t := secret.Threshold
+	commitments := append([]byte{}, secret.Commitments...)
+	chunks := len(commitments) / 32 / t
+	for x, share := range shares {
+		if err := verifyShare(secret, x, share); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	refreshed := make(map[byte][]byte, len(shares))
+	for c := 0; c < chunks; c++ {
+		// Random polynomials z and w, where z(0) is zero
+		z := make([]*edwards25519.Scalar, t)
+		w := make([]*edwards25519.Scalar, t)
+		for j := range z {
+			var err error
+			if z[j], err = randomScalar(); err != nil {
+				return nil, nil, err
+			}
+			if w[j], err = randomScalar(); err != nil {
+				return nil, nil, err
+			}
+		}
+		z[0] = edwards25519.NewScalar()
+
+		for j := range z {
+			offset := (c*t + j) * 32
+			cj, err := new(edwards25519.Point).SetBytes(commitments[offset : offset+32])
+			if err != nil {
+				return nil, nil, errInvalidShare
+			}
+
+			copy(commitments[offset:], cj.Add(cj, commit(z[j], w[j])).Bytes())
+		}
+
+		for x, share := range shares {
+			s, _ := edwards25519.NewScalar().SetCanonicalBytes(share[c*64 : c*64+32])
+			r, _ := edwards25519.NewScalar().SetCanonicalBytes(share[c*64+32 : c*64+64])
+
+			refreshed[x] = append(refreshed[x], s.Add(s, evaluate(z, x)).Bytes()...)
+			refreshed[x] = append(refreshed[x], r.Add(r, evaluate(w, x)).Bytes()...)
+		}
+	}
+
+	return refreshed, commitments, nil
+}
+
+// validateParts checks the parts and threshold of a pedersen split.
+func validateParts(parts, threshold int) error {
+	if parts < 1 || parts > 255 {
+		return errors.New("A secret can be split into 1 to 255 parts.")
+	}
+	if threshold < 1 || threshold > parts {
+		return errors.New("The threshold must be between 1 and the number of parts.")
+	}
+
+	return nil
+}
+
+func randomScalar() (*edwards25519.Scalar, error) {
+	b := make([]byte, 64)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+
+	return edwards25519.NewScalar().SetUniformBytes(b)
+}
+
+func scalarFromByte(x byte) *edwards25519.Scalar {
+	b := make([]byte, 32)
+	b[0] = x
+
+	s, _ := edwards25519.NewScalar().SetCanonicalBytes(b)
+	return s
+}
+
+// commit returns a*G + b*H.
+func commit(a, b *edwards25519.Scalar) *edwards25519.Point {
+	p := new(edwards25519.Point).ScalarBaseMult(a)
+	return p.Add(p, new(edwards25519.Point).ScalarMult(b, pedersenH))
+}
+
+// evaluate returns the polynomial with the given coefficients at x.
+func evaluate(coefficients []*edwards25519.Scalar, x byte) *edwards25519.Scalar {
+	y := edwards25519.NewScalar()
+	for j := len(coefficients) - 1; j >= 0; j-- {
+		y.Multiply(y, scalarFromByte(x))
+		y.Add(y, coefficients[j])
+	}
+
+	return y
+}
+
+// lagrange returns the Lagrange coefficient of x at 0, over the xs.
+func lagrange(xs []byte, x byte) *edwards25519.Scalar {
+	num, den := scalarFromByte(1), scalarFromByte(1)
+	for _, xj := range xs {
+		if xj == x {
+			continue
+		}
+
+		num.Multiply(num, scalarFromByte(xj))
+		den.Multiply(den, edwards25519.NewScalar().Subtract(scalarFromByte(xj), scalarFromByte(x)))
+	}
+
+	return num.Multiply(num, den.Invert(den))
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/adamgoose/ssss/lib/model"
+)
+
+func pedersenSecret(parts, threshold int) *model.Secret {
+	return &model.Secret{Parts: parts, Threshold: threshold, Scheme: schemePedersen}
+}
+
+func TestSplitCombineChunks(t *testing.T) {
+	for _, size := range []int{0, 1, 30, 31, 32, 100} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			plaintext := bytes.Repeat([]byte{0x80}, size)
+			secret := pedersenSecret(5, 3)
+
+			shares, err := splitSecret(secret, plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(shares) != 5 {
+				t.Fatalf("got %d shares, want 5", len(shares))
+			}
+
+			for x, share := range shares {
+				if err := verifyShare(secret, x, share); err != nil {
+					t.Fatalf("share %d: %v", x, err)
+				}
+			}
+
+			// Any threshold of the shares recovers the secret
+			for _, xs := range [][]byte{{1, 2, 3}, {3, 4, 5}, {1, 3, 5}, {1, 2, 3, 4, 5}} {
+				subset := map[byte][]byte{}
+				for _, x := range xs {
+					subset[x] = shares[x]
+				}
+
+				recovered, err := combineSecret(secret, subset)
+				if err != nil {
+					t.Fatalf("shares %v: %v", xs, err)
+				}
+				if !bytes.Equal(recovered, plaintext) {
+					t.Fatalf("shares %v recovered %x, want %x", xs, recovered, plaintext)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyShareTampered(t *testing.T) {
+	secret := pedersenSecret(3, 2)
+	shares, err := splitSecret(secret, []byte("a secret that takes more than one chunk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := append([]byte{}, shares[1]...)
+	tampered[0] ^= 1
+	if err := verifyShare(secret, 1, tampered); err == nil {
+		t.Fatal("verified a tampered share")
+	}
+
+	// A share is only valid at its own x coordinate
+	if err := verifyShare(secret, 2, shares[1]); err == nil {
+		t.Fatal("verified a share at another x coordinate")
+	}
+
+	if err := verifyShare(secret, 1, shares[1][:64]); err == nil {
+		t.Fatal("verified a truncated share")
+	}
+}
+
+func TestCombineBelowThreshold(t *testing.T) {
+	plaintext := []byte("a secret")
+	secret := pedersenSecret(3, 2)
+	shares, err := splitSecret(secret, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := combineSecret(secret, map[byte][]byte{1: shares[1]}); err == nil {
+		t.Fatal("combined fewer shares than the threshold")
+	}
+
+	if _, err := combineSecret(secret, map[byte][]byte{}); err == nil {
+		t.Fatal("combined no shares")
+	}
+}
+
+func TestValidateParts(t *testing.T) {
+	for _, c := range []struct {
+		parts, threshold int
+		valid            bool
+	}{
+		{3, 2, true},
+		{1, 1, true},
+		{255, 255, true},
+		{0, 0, false},
+		{256, 2, false},
+		{3, 0, false},
+		{3, 4, false},
+	} {
+		if err := validateParts(c.parts, c.threshold); (err == nil) != c.valid {
+			t.Errorf("validateParts(%d, %d) = %v", c.parts, c.threshold, err)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+type Ceremony struct {
+	ID     string `json:"id,omitempty"`
+	Secret string `json:"secret"`
+	User   string `json:"user"`
+
+	Kind         string        `json:"kind"`
+	Expected     int           `json:"expected"`
+	Participants []Participant `json:"participants"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+}
+
+type Participant struct {
+	User     string    `json:"user"`
+	Username string    `json:"username"`
+	JoinedAt time.Time `json:"joined_at"`
+}
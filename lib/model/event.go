@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+const (
+	EventSecretCreated    = "secret.created"
+	EventShareSigned      = "share.signed"
+	EventSplitCancelled   = "split.cancelled"
+	EventSplitExpired     = "split.expired"
+	EventSplitFailed      = "split.failed"
+	EventCombineStarted   = "combine.started"
+	EventShareUnsigned    = "share.unsigned"
+	EventShareRejected    = "share.rejected"
+	EventSecretRecovered  = "secret.recovered"
+	EventRecoveryFailed   = "recovery.failed"
+	EventCombineCancelled = "combine.cancelled"
+	EventCombineExpired   = "combine.expired"
+	EventReshareStarted   = "reshare.started"
+	EventSecretReshared   = "secret.reshared"
+	EventReshareCancelled = "reshare.cancelled"
+	EventReshareExpired   = "reshare.expired"
+	EventRefreshStarted   = "refresh.started"
+	EventSharesRefreshed  = "shares.refreshed"
+	EventRefreshCancelled = "refresh.cancelled"
+	EventRefreshExpired   = "refresh.expired"
+	EventSecretArchived   = "secret.archived"
+	EventSecretUnarchived = "secret.unarchived"
+	EventSecretRelabeled  = "secret.relabeled"
+	EventSecretDeleted    = "secret.deleted"
+
+	// Admin actions, audited along with the user or secret they acted on
+	EventUserApproved        = "user.approved"
+	EventUserRejected        = "user.rejected"
+	EventUserDisabled        = "user.disabled"
+	EventUserEnabled         = "user.enabled"
+	EventCeremonyKilled      = "ceremony.killed"
+	EventSecretsListed       = "admin.secrets.listed"
+	EventCeremoniesListed    = "admin.ceremonies.listed"
+	EventUsersListed         = "admin.users.listed"
+	EventRegistrationsListed = "admin.registrations.listed"
+	EventAdminAuditListed    = "admin.audit.listed"
+)
+
+type Event struct {
+	ID     string `json:"id,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	User   string `json:"user"`
+	// Subject is the user an admin acted on
+	Subject string `json:"subject,omitempty"`
+
+	Action    string    `json:"action"`
+	CreatedAt time.Time `json:"created_at"`
+}
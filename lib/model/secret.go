@@ -6,9 +6,19 @@ type Secret struct {
 	ID   string `json:"id,omitempty"`
 	User string `json:"user"`
 
-	Label     string    `json:"label"`
-	Parts     int       `json:"parts"`
-	Threshold int       `json:"threshold"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	Label          string   `json:"label"`
+	Parts          int      `json:"parts"`
+	Threshold      int      `json:"threshold"`
+	Shareholders   []string `json:"shareholders"`
+	Recipients     []string `json:"recipients"`
+	MultipleShares bool     `json:"multiple_shares"`
+	Encryption     string   `json:"encryption"`
+	Scheme         string   `json:"scheme"`
+	Commitments    []byte   `json:"commitments,omitempty"`
+	Digest         []byte   `json:"digest,omitempty"`
+	// Generation is the generation of the secret's current shares
+	Generation int       `json:"generation"`
+	Status     string    `json:"status"`
+	Archived   bool      `json:"archived"`
+	CreatedAt  time.Time `json:"created_at"`
 }
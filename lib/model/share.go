@@ -5,6 +5,8 @@ type Share struct {
 	Secret string `json:"secret"`
 	User   string `json:"user"`
 
-	Key   byte   `json:"key"`
-	Share []byte `json:"share"`
+	Key        byte   `json:"key"`
+	Share      []byte `json:"share"`
+	Generation int    `json:"generation"`
+	Revoked    bool   `json:"revoked"`
 }
@@ -1,9 +1,55 @@
 package model
 
+import "time"
+
 type User struct {
-	ID        string `json:"id,omitempty"`
-	Username  string `json:"username"`
-	PublicKey string `json:"public_key"`
-	// FirstSeen time.Time `json:"first_seen"`
-	// LastSeen  time.Time `json:"last_seen"`
+	ID       string `json:"id,omitempty"`
+	Username string `json:"username"`
+	// PublicKey is the key the user registered with. For the user of a
+	// session, it is the key they connected with.
+	PublicKey string   `json:"public_key"`
+	Keys      []string `json:"keys"`
+	// Verified are the keys the user authenticated with.
+	Verified  []string  `json:"verified"`
+	Status    string    `json:"status"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// HasKey reports whether the public key is one of the user's verified keys.
+// Users registered before they could have several keys only have their
+// PublicKey.
+func (u User) HasKey(publicKey string) bool {
+	if len(u.Verified) == 0 {
+		return u.PublicKey == publicKey
+	}
+
+	for _, key := range u.Verified {
+		if key == publicKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verify records that the user authenticated with the public key, adding it
+// to their keys.
+func (u *User) Verify(publicKey string) {
+	if len(u.Verified) == 0 {
+		u.Verified = []string{u.PublicKey}
+	}
+	if len(u.Keys) == 0 {
+		u.Keys = []string{u.PublicKey}
+	}
+
+	if !u.HasKey(publicKey) {
+		u.Verified = append(u.Verified, publicKey)
+	}
+	for _, key := range u.Keys {
+		if key == publicKey {
+			return
+		}
+	}
+	u.Keys = append(u.Keys, publicKey)
 }
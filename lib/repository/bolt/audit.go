@@ -0,0 +1,55 @@
+package bolt
+
+import (
+	"sort"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+	"go.etcd.io/bbolt"
+)
+
+type BoltAuditRepository struct {
+	di.Inject
+	DB *bbolt.DB
+}
+
+func (r BoltAuditRepository) Append(event *model.Event) (*model.Event, error) {
+	ne := *event
+	ne.ID = repository.NewID("events")
+
+	err := r.DB.Update(func(tx *bbolt.Tx) error {
+		return put(tx, "events", ne.ID, ne)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ne, nil
+}
+
+func (r BoltAuditRepository) All() ([]model.Event, error) {
+	return r.where(func(model.Event) bool {
+		return true
+	})
+}
+
+func (r BoltAuditRepository) ForSecret(secretID string) ([]model.Event, error) {
+	return r.where(func(e model.Event) bool {
+		return e.Secret == secretID
+	})
+}
+
+// where lists the matching events, oldest first.
+func (r BoltAuditRepository) where(match func(model.Event) bool) (events []model.Event, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		events, err = where(tx, "events", match)
+		return err
+	})
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+
+	return
+}
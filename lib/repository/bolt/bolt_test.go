@@ -0,0 +1,29 @@
+package bolt_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/adamgoose/ssss/lib/repository/bolt"
+	"github.com/adamgoose/ssss/lib/repository/repotest"
+	"github.com/defval/di"
+)
+
+func TestBoltRepository(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "ssss.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := lib.Apply(
+		di.ProvideValue(db),
+		di.ProvideValue(bolt.BoltRepository{}, di.As(new(repository.Repository))),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.Run(t, lib.MustAutoResolve[repository.Repository]())
+}
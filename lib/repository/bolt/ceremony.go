@@ -0,0 +1,69 @@
+package bolt
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+	"go.etcd.io/bbolt"
+)
+
+type BoltCeremonyRepository struct {
+	di.Inject
+	DB *bbolt.DB
+}
+
+func (r BoltCeremonyRepository) All() (ceremonies []model.Ceremony, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		ceremonies, err = where(tx, "ceremonies", func(model.Ceremony) bool {
+			return true
+		})
+		return err
+	})
+
+	return
+}
+
+func (r BoltCeremonyRepository) ForSecret(secretID string) (*model.Ceremony, error) {
+	var ceremonies []model.Ceremony
+	err := r.DB.View(func(tx *bbolt.Tx) (err error) {
+		ceremonies, err = where(tx, "ceremonies", func(c model.Ceremony) bool {
+			return c.Secret == secretID
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ceremonies) == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return &ceremonies[0], nil
+}
+
+func (r BoltCeremonyRepository) Create(ceremony *model.Ceremony) (*model.Ceremony, error) {
+	nc := *ceremony
+	nc.ID = repository.NewID("ceremonies")
+
+	err := r.DB.Update(func(tx *bbolt.Tx) error {
+		return put(tx, "ceremonies", nc.ID, nc)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &nc, nil
+}
+
+func (r BoltCeremonyRepository) Update(ceremony *model.Ceremony) error {
+	return r.DB.Update(func(tx *bbolt.Tx) error {
+		return update(tx, "ceremonies", ceremony.ID, ceremony)
+	})
+}
+
+func (r BoltCeremonyRepository) Delete(id string) error {
+	return r.DB.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte("ceremonies")).Delete([]byte(id))
+	})
+}
@@ -0,0 +1,162 @@
+package bolt
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/repository"
+	"go.etcd.io/bbolt"
+)
+
+var _ repository.Repository = BoltRepository{}
+
+type BoltRepository struct {
+	//
+}
+
+func (r BoltRepository) User() repository.UserRepository {
+	return lib.MustAutoResolve[BoltUserRepository]()
+}
+
+func (r BoltRepository) Share() repository.ShareRepository {
+	return lib.MustAutoResolve[BoltShareRepository]()
+}
+
+func (r BoltRepository) Secret() repository.SecretRepository {
+	return lib.MustAutoResolve[BoltSecretRepository]()
+}
+
+func (r BoltRepository) Ceremony() repository.CeremonyRepository {
+	return lib.MustAutoResolve[BoltCeremonyRepository]()
+}
+
+func (r BoltRepository) Audit() repository.AuditRepository {
+	return lib.MustAutoResolve[BoltAuditRepository]()
+}
+
+// Records are stored as JSON in one bucket per table, keyed by record ID.
+var tables = []string{"users", "secrets", "shares", "ceremonies", "events"}
+
+// Graph edges mirror the SurrealDB relations. Each edge bucket holds a
+// nested bucket per "in" record, whose keys are the related "out" records.
+var edges = []string{"created", "split_into", "signed"}
+
+// Open opens the bolt database at path, creating any missing buckets.
+func Open(path string) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range append(tables, edges...) {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func get[T any](tx *bbolt.Tx, table string, id string) (*T, error) {
+	data := tx.Bucket([]byte(table)).Get([]byte(id))
+	if data == nil {
+		return nil, repository.ErrNotFound
+	}
+
+	v := new(T)
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func put(tx *bbolt.Tx, table string, id string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte(table)).Put([]byte(id), data)
+}
+
+func update(tx *bbolt.Tx, table string, id string, v any) error {
+	if tx.Bucket([]byte(table)).Get([]byte(id)) == nil {
+		return repository.ErrNotFound
+	}
+
+	return put(tx, table, id, v)
+}
+
+func where[T any](tx *bbolt.Tx, table string, match func(T) bool) ([]T, error) {
+	result := []T{}
+	err := tx.Bucket([]byte(table)).ForEach(func(_, data []byte) error {
+		var v T
+		if err := json.Unmarshal(data, &v); err != nil {
+			return err
+		}
+		if match(v) {
+			result = append(result, v)
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+func remove(tx *bbolt.Tx, table string, id string) error {
+	return tx.Bucket([]byte(table)).Delete([]byte(id))
+}
+
+// relate records the in->edge->out relation.
+func relate(tx *bbolt.Tx, in string, edge string, out string) error {
+	b, err := tx.Bucket([]byte(edge)).CreateBucketIfNotExists([]byte(in))
+	if err != nil {
+		return err
+	}
+
+	return b.Put([]byte(out), []byte{})
+}
+
+// related lists the records related to in through edge.
+func related(tx *bbolt.Tx, in string, edge string) []string {
+	out := []string{}
+
+	b := tx.Bucket([]byte(edge)).Bucket([]byte(in))
+	if b == nil {
+		return out
+	}
+
+	b.ForEach(func(k, _ []byte) error {
+		out = append(out, string(k))
+		return nil
+	})
+
+	return out
+}
+
+// unrelate removes the in->edge->out relation.
+func unrelate(tx *bbolt.Tx, in string, edge string, out string) error {
+	b := tx.Bucket([]byte(edge)).Bucket([]byte(in))
+	if b == nil {
+		return nil
+	}
+
+	return b.Delete([]byte(out))
+}
+
+// unrelateAll removes every relation of in through edge.
+func unrelateAll(tx *bbolt.Tx, in string, edge string) error {
+	if tx.Bucket([]byte(edge)).Bucket([]byte(in)) == nil {
+		return nil
+	}
+
+	return tx.Bucket([]byte(edge)).DeleteBucket([]byte(in))
+}
@@ -0,0 +1,142 @@
+package bolt
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+	"go.etcd.io/bbolt"
+)
+
+type BoltSecretRepository struct {
+	di.Inject
+	DB *bbolt.DB
+}
+
+func (r BoltSecretRepository) Get(id string) (secret *model.Secret, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		secret, err = get[model.Secret](tx, "secrets", "secrets:"+id)
+		return err
+	})
+
+	return
+}
+
+func (r BoltSecretRepository) All() (secrets []model.Secret, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		secrets, err = where(tx, "secrets", func(model.Secret) bool {
+			return true
+		})
+		return err
+	})
+
+	return
+}
+
+func (r BoltSecretRepository) Mine(userID string) (secrets []model.Secret, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		secrets = []model.Secret{}
+		for _, id := range related(tx, userID, "created") {
+			secret, err := get[model.Secret](tx, "secrets", id)
+			if err != nil {
+				return err
+			}
+			secrets = append(secrets, *secret)
+		}
+		return nil
+	})
+
+	return
+}
+
+func (r BoltSecretRepository) Held(userID string) (secrets []model.Secret, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		held := map[string]bool{}
+		secrets = []model.Secret{}
+		for _, id := range related(tx, userID, "signed") {
+			share, err := get[model.Share](tx, "shares", id)
+			if err != nil {
+				return err
+			}
+			if share.Revoked || held[share.Secret] {
+				continue
+			}
+			held[share.Secret] = true
+
+			secret, err := get[model.Secret](tx, "secrets", share.Secret)
+			if err != nil {
+				return err
+			}
+			secrets = append(secrets, *secret)
+		}
+		return nil
+	})
+
+	return
+}
+
+func (r BoltSecretRepository) WithStatus(status string) (secrets []model.Secret, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		secrets, err = where(tx, "secrets", func(s model.Secret) bool {
+			return s.Status == status
+		})
+		return err
+	})
+
+	return
+}
+
+func (r BoltSecretRepository) Create(secret *model.Secret) (*model.Secret, error) {
+	ns := *secret
+	ns.ID = repository.NewID("secrets")
+
+	err := r.DB.Update(func(tx *bbolt.Tx) error {
+		if err := put(tx, "secrets", ns.ID, ns); err != nil {
+			return err
+		}
+
+		return relate(tx, ns.User, "created", ns.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ns, nil
+}
+
+func (r BoltSecretRepository) Update(secret *model.Secret) error {
+	return r.DB.Update(func(tx *bbolt.Tx) error {
+		return update(tx, "secrets", secret.ID, secret)
+	})
+}
+
+func (r BoltSecretRepository) Delete(id string) error {
+	return r.DB.Update(func(tx *bbolt.Tx) error {
+		secret, err := get[model.Secret](tx, "secrets", id)
+		if err != nil {
+			return err
+		}
+
+		for _, shareID := range related(tx, id, "split_into") {
+			share, err := get[model.Share](tx, "shares", shareID)
+			if err != nil {
+				return err
+			}
+
+			if err := unrelate(tx, share.User, "signed", shareID); err != nil {
+				return err
+			}
+			if err := remove(tx, "shares", shareID); err != nil {
+				return err
+			}
+		}
+
+		if err := unrelateAll(tx, id, "split_into"); err != nil {
+			return err
+		}
+		if err := unrelate(tx, secret.User, "created", id); err != nil {
+			return err
+		}
+
+		return remove(tx, "secrets", id)
+	})
+}
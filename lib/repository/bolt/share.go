@@ -0,0 +1,88 @@
+package bolt
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+	"go.etcd.io/bbolt"
+)
+
+type BoltShareRepository struct {
+	di.Inject
+	DB *bbolt.DB
+}
+
+func (r BoltShareRepository) ForSecret(secretID string) (shares []model.Share, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		shares = []model.Share{}
+		for _, id := range related(tx, secretID, "split_into") {
+			share, err := get[model.Share](tx, "shares", id)
+			if err != nil {
+				return err
+			}
+			if share.Revoked {
+				continue
+			}
+			shares = append(shares, *share)
+		}
+		return nil
+	})
+
+	return
+}
+
+func (r BoltShareRepository) MineForSecret(secretID string, userID string) (shares []model.Share, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		signed := map[string]bool{}
+		for _, id := range related(tx, userID, "signed") {
+			signed[id] = true
+		}
+
+		shares = []model.Share{}
+		for _, id := range related(tx, secretID, "split_into") {
+			if !signed[id] {
+				continue
+			}
+
+			share, err := get[model.Share](tx, "shares", id)
+			if err != nil {
+				return err
+			}
+			if share.Revoked {
+				continue
+			}
+			shares = append(shares, *share)
+		}
+		return nil
+	})
+
+	return
+}
+
+func (r BoltShareRepository) Create(share *model.Share) (*model.Share, error) {
+	ns := *share
+	ns.ID = repository.NewID("shares")
+
+	err := r.DB.Update(func(tx *bbolt.Tx) error {
+		if err := put(tx, "shares", ns.ID, ns); err != nil {
+			return err
+		}
+
+		if err := relate(tx, ns.Secret, "split_into", ns.ID); err != nil {
+			return err
+		}
+
+		return relate(tx, ns.User, "signed", ns.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ns, nil
+}
+
+func (r BoltShareRepository) Update(share *model.Share) error {
+	return r.DB.Update(func(tx *bbolt.Tx) error {
+		return update(tx, "shares", share.ID, share)
+	})
+}
@@ -0,0 +1,97 @@
+package bolt
+
+import (
+	"time"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+	"go.etcd.io/bbolt"
+)
+
+type BoltUserRepository struct {
+	di.Inject
+	DB *bbolt.DB
+}
+
+func (r BoltUserRepository) Get(id string) (user *model.User, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		user, err = get[model.User](tx, "users", id)
+		return err
+	})
+
+	return
+}
+
+func (r BoltUserRepository) ForKey(publicKey string) (user *model.User, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		users, err := where(tx, "users", func(u model.User) bool {
+			return u.HasKey(publicKey)
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(users) == 0 {
+			return repository.ErrNotFound
+		}
+
+		user = &users[0]
+		return nil
+	})
+
+	return
+}
+
+func (r BoltUserRepository) List() (users []model.User, err error) {
+	err = r.DB.View(func(tx *bbolt.Tx) error {
+		users, err = where(tx, "users", func(model.User) bool { return true })
+		return err
+	})
+
+	return
+}
+
+func (r BoltUserRepository) Upsert(user *model.User) (*model.User, error) {
+	var nu model.User
+	err := r.DB.Update(func(tx *bbolt.Tx) error {
+		users, err := where(tx, "users", func(u model.User) bool {
+			return u.HasKey(user.PublicKey) || u.Username == user.Username
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, u := range users {
+			if u.HasKey(user.PublicKey) {
+				nu = u
+				nu.LastSeen = now
+				return update(tx, "users", nu.ID, nu)
+			}
+		}
+
+		if len(users) > 0 {
+			return repository.ErrUsernameTaken
+		}
+
+		nu = *user
+		nu.ID = repository.NewID("users")
+		nu.Keys = []string{user.PublicKey}
+		nu.Verified = []string{user.PublicKey}
+		nu.FirstSeen = now
+		nu.LastSeen = now
+		return put(tx, "users", nu.ID, nu)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &nu, nil
+}
+
+func (r BoltUserRepository) Update(user *model.User) error {
+	return r.DB.Update(func(tx *bbolt.Tx) error {
+		return update(tx, "users", user.ID, user)
+	})
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+const idAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// NewID generates a record ID shaped like the ones SurrealDB generates, for
+// backends that have to assign their own.
+func NewID(table string) string {
+	id := make([]byte, 20)
+	for i := range id {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(idAlphabet))))
+		if err != nil {
+			panic(err)
+		}
+		id[i] = idAlphabet[n.Int64()]
+	}
+
+	return table + ":" + string(id)
+}
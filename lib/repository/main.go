@@ -1,24 +1,68 @@
 package repository
 
-import "github.com/adamgoose/ssss/lib/model"
+import (
+	"errors"
+
+	"github.com/adamgoose/ssss/lib/model"
+)
+
+var ErrNotFound = errors.New("record not found")
+
+// ErrUsernameTaken is returned when a new key registers a username another
+// user already registered.
+var ErrUsernameTaken = errors.New("username is taken")
 
 type Repository interface {
 	User() UserRepository
 	Share() ShareRepository
 	Secret() SecretRepository
+	Ceremony() CeremonyRepository
+	Audit() AuditRepository
 }
+
+// UserRepository identifies users by their keys. Upsert looks up the user
+// owning user.PublicKey, or registers them with it as their first key.
 type UserRepository interface {
+	Get(id string) (*model.User, error)
+	ForKey(publicKey string) (*model.User, error)
+	List() ([]model.User, error)
 	Upsert(user *model.User) (*model.User, error)
+	Update(user *model.User) error
 }
 
+// ShareRepository only lists the current shares of a secret, leaving out
+// the ones revoked by a reshare.
 type ShareRepository interface {
+	ForSecret(secretID string) ([]model.Share, error)
 	MineForSecret(secretID string, userID string) ([]model.Share, error)
 	Create(share *model.Share) (*model.Share, error)
+	Update(share *model.Share) error
 }
 
 type SecretRepository interface {
+	All() ([]model.Secret, error)
 	Get(id string) (*model.Secret, error)
 	Mine(userID string) ([]model.Secret, error)
+	// Held lists the secrets the user holds current shares of.
+	Held(userID string) ([]model.Secret, error)
+	WithStatus(status string) ([]model.Secret, error)
 	Create(secret *model.Secret) (*model.Secret, error)
 	Update(secret *model.Secret) error
+	// Delete removes the secret along with its shares and their relations.
+	Delete(id string) error
+}
+
+type CeremonyRepository interface {
+	All() ([]model.Ceremony, error)
+	ForSecret(secretID string) (*model.Ceremony, error)
+	Create(ceremony *model.Ceremony) (*model.Ceremony, error)
+	Update(ceremony *model.Ceremony) error
+	Delete(id string) error
+}
+
+// AuditRepository is append-only: events are never updated or deleted.
+type AuditRepository interface {
+	Append(event *model.Event) (*model.Event, error)
+	All() ([]model.Event, error)
+	ForSecret(secretID string) ([]model.Event, error)
 }
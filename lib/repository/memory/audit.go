@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+)
+
+type MemoryAuditRepository struct {
+	di.Inject
+	Store *Store
+}
+
+func (r MemoryAuditRepository) Append(event *model.Event) (*model.Event, error) {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	ne := *event
+	ne.ID = repository.NewID("events")
+	r.Store.events = append(r.Store.events, ne)
+
+	return &ne, nil
+}
+
+func (r MemoryAuditRepository) All() ([]model.Event, error) {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	return append([]model.Event{}, r.Store.events...), nil
+}
+
+func (r MemoryAuditRepository) ForSecret(secretID string) ([]model.Event, error) {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	events := []model.Event{}
+	for _, e := range r.Store.events {
+		if e.Secret == secretID {
+			events = append(events, e)
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+)
+
+type MemoryCeremonyRepository struct {
+	di.Inject
+	Store *Store
+}
+
+func (r MemoryCeremonyRepository) All() ([]model.Ceremony, error) {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	ceremonies := []model.Ceremony{}
+	for _, c := range r.Store.ceremonies {
+		ceremonies = append(ceremonies, c)
+	}
+
+	return ceremonies, nil
+}
+
+func (r MemoryCeremonyRepository) ForSecret(secretID string) (*model.Ceremony, error) {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	for _, c := range r.Store.ceremonies {
+		if c.Secret == secretID {
+			return &c, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func (r MemoryCeremonyRepository) Create(ceremony *model.Ceremony) (*model.Ceremony, error) {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	nc := *ceremony
+	nc.ID = repository.NewID("ceremonies")
+	r.Store.ceremonies[nc.ID] = nc
+
+	return &nc, nil
+}
+
+func (r MemoryCeremonyRepository) Update(ceremony *model.Ceremony) error {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	if _, ok := r.Store.ceremonies[ceremony.ID]; !ok {
+		return repository.ErrNotFound
+	}
+
+	r.Store.ceremonies[ceremony.ID] = *ceremony
+	return nil
+}
+
+func (r MemoryCeremonyRepository) Delete(id string) error {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	delete(r.Store.ceremonies, id)
+	return nil
+}
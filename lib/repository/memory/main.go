@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+)
+
+var _ repository.Repository = MemoryRepository{}
+
+type MemoryRepository struct {
+	//
+}
+
+func (r MemoryRepository) User() repository.UserRepository {
+	return lib.MustAutoResolve[MemoryUserRepository]()
+}
+
+func (r MemoryRepository) Share() repository.ShareRepository {
+	return lib.MustAutoResolve[MemoryShareRepository]()
+}
+
+func (r MemoryRepository) Secret() repository.SecretRepository {
+	return lib.MustAutoResolve[MemorySecretRepository]()
+}
+
+func (r MemoryRepository) Ceremony() repository.CeremonyRepository {
+	return lib.MustAutoResolve[MemoryCeremonyRepository]()
+}
+
+func (r MemoryRepository) Audit() repository.AuditRepository {
+	return lib.MustAutoResolve[MemoryAuditRepository]()
+}
+
+// Store holds every record of the in-memory repository, keyed by record ID.
+type Store struct {
+	mu sync.RWMutex
+
+	users      map[string]model.User
+	secrets    map[string]model.Secret
+	shares     map[string]model.Share
+	ceremonies map[string]model.Ceremony
+	events     []model.Event
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:      make(map[string]model.User),
+		secrets:    make(map[string]model.Secret),
+		shares:     make(map[string]model.Share),
+		ceremonies: make(map[string]model.Ceremony),
+		events:     make([]model.Event, 0),
+	}
+}
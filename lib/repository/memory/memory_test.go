@@ -0,0 +1,22 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/adamgoose/ssss/lib"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/adamgoose/ssss/lib/repository/memory"
+	"github.com/adamgoose/ssss/lib/repository/repotest"
+	"github.com/defval/di"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	if err := lib.Apply(
+		di.Provide(memory.NewStore),
+		di.ProvideValue(memory.MemoryRepository{}, di.As(new(repository.Repository))),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.Run(t, lib.MustAutoResolve[repository.Repository]())
+}
@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+)
+
+type MemorySecretRepository struct {
+	di.Inject
+	Store *Store
+}
+
+func (r MemorySecretRepository) Get(id string) (*model.Secret, error) {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	secret, ok := r.Store.secrets["secrets:"+id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return &secret, nil
+}
+
+func (r MemorySecretRepository) All() ([]model.Secret, error) {
+	return r.where(func(model.Secret) bool {
+		return true
+	}), nil
+}
+
+func (r MemorySecretRepository) Mine(userID string) ([]model.Secret, error) {
+	return r.where(func(s model.Secret) bool {
+		return s.User == userID
+	}), nil
+}
+
+func (r MemorySecretRepository) Held(userID string) ([]model.Secret, error) {
+	r.Store.mu.RLock()
+	held := map[string]bool{}
+	for _, s := range r.Store.shares {
+		if s.User == userID && !s.Revoked {
+			held[s.Secret] = true
+		}
+	}
+	r.Store.mu.RUnlock()
+
+	return r.where(func(s model.Secret) bool {
+		return held[s.ID]
+	}), nil
+}
+
+func (r MemorySecretRepository) WithStatus(status string) ([]model.Secret, error) {
+	return r.where(func(s model.Secret) bool {
+		return s.Status == status
+	}), nil
+}
+
+func (r MemorySecretRepository) Create(secret *model.Secret) (*model.Secret, error) {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	ns := *secret
+	ns.ID = repository.NewID("secrets")
+	r.Store.secrets[ns.ID] = ns
+
+	return &ns, nil
+}
+
+func (r MemorySecretRepository) Update(secret *model.Secret) error {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	if _, ok := r.Store.secrets[secret.ID]; !ok {
+		return repository.ErrNotFound
+	}
+
+	r.Store.secrets[secret.ID] = *secret
+	return nil
+}
+
+func (r MemorySecretRepository) Delete(id string) error {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	if _, ok := r.Store.secrets[id]; !ok {
+		return repository.ErrNotFound
+	}
+
+	for shareID, share := range r.Store.shares {
+		if share.Secret == id {
+			delete(r.Store.shares, shareID)
+		}
+	}
+	delete(r.Store.secrets, id)
+
+	return nil
+}
+
+func (r MemorySecretRepository) where(match func(model.Secret) bool) []model.Secret {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	secrets := []model.Secret{}
+	for _, s := range r.Store.secrets {
+		if match(s) {
+			secrets = append(secrets, s)
+		}
+	}
+
+	return secrets
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+)
+
+type MemoryShareRepository struct {
+	di.Inject
+	Store *Store
+}
+
+func (r MemoryShareRepository) ForSecret(secretID string) ([]model.Share, error) {
+	return r.where(func(s model.Share) bool {
+		return s.Secret == secretID && !s.Revoked
+	}), nil
+}
+
+func (r MemoryShareRepository) MineForSecret(secretID string, userID string) ([]model.Share, error) {
+	return r.where(func(s model.Share) bool {
+		return s.Secret == secretID && s.User == userID && !s.Revoked
+	}), nil
+}
+
+func (r MemoryShareRepository) Create(share *model.Share) (*model.Share, error) {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	ns := *share
+	ns.ID = repository.NewID("shares")
+	r.Store.shares[ns.ID] = ns
+
+	return &ns, nil
+}
+
+func (r MemoryShareRepository) Update(share *model.Share) error {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	if _, ok := r.Store.shares[share.ID]; !ok {
+		return repository.ErrNotFound
+	}
+
+	r.Store.shares[share.ID] = *share
+	return nil
+}
+
+func (r MemoryShareRepository) where(match func(model.Share) bool) []model.Share {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	shares := []model.Share{}
+	for _, s := range r.Store.shares {
+		if match(s) {
+			shares = append(shares, s)
+		}
+	}
+
+	return shares
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+)
+
+type MemoryUserRepository struct {
+	di.Inject
+	Store *Store
+}
+
+func (r MemoryUserRepository) Get(id string) (*model.User, error) {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	user, ok := r.Store.users[id]
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	return &user, nil
+}
+
+func (r MemoryUserRepository) ForKey(publicKey string) (*model.User, error) {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	for _, u := range r.Store.users {
+		if u.HasKey(publicKey) {
+			return &u, nil
+		}
+	}
+
+	return nil, repository.ErrNotFound
+}
+
+func (r MemoryUserRepository) List() ([]model.User, error) {
+	r.Store.mu.RLock()
+	defer r.Store.mu.RUnlock()
+
+	users := make([]model.User, 0, len(r.Store.users))
+	for _, u := range r.Store.users {
+		users = append(users, u)
+	}
+
+	return users, nil
+}
+
+func (r MemoryUserRepository) Upsert(user *model.User) (*model.User, error) {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	now := time.Now()
+	for _, u := range r.Store.users {
+		if u.HasKey(user.PublicKey) {
+			u.LastSeen = now
+			r.Store.users[u.ID] = u
+			return &u, nil
+		}
+	}
+
+	for _, u := range r.Store.users {
+		if u.Username == user.Username {
+			return nil, repository.ErrUsernameTaken
+		}
+	}
+
+	nu := *user
+	nu.ID = repository.NewID("users")
+	nu.Keys = []string{user.PublicKey}
+	nu.Verified = []string{user.PublicKey}
+	nu.FirstSeen = now
+	nu.LastSeen = now
+	r.Store.users[nu.ID] = nu
+
+	return &nu, nil
+}
+
+func (r MemoryUserRepository) Update(user *model.User) error {
+	r.Store.mu.Lock()
+	defer r.Store.mu.Unlock()
+
+	if _, ok := r.Store.users[user.ID]; !ok {
+		return repository.ErrNotFound
+	}
+
+	r.Store.users[user.ID] = *user
+	return nil
+}
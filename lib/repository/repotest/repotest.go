@@ -0,0 +1,353 @@
+// Package repotest checks that a repository.Repository behaves the way the
+// rest of ssss expects, whichever storage driver backs it.
+package repotest
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+)
+
+// Run runs the repository contract against repo, which should start empty.
+func Run(t *testing.T, repo repository.Repository) {
+	t.Run("Users", func(t *testing.T) { testUsers(t, repo) })
+	t.Run("Secrets", func(t *testing.T) { testSecrets(t, repo) })
+	t.Run("Shares", func(t *testing.T) { testShares(t, repo) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, repo) })
+}
+
+func upsertUser(t *testing.T, repo repository.Repository, username string) *model.User {
+	t.Helper()
+
+	user, err := repo.User().Upsert(&model.User{
+		Username:  username,
+		PublicKey: "key-" + username,
+		Status:    "active",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func createSecret(t *testing.T, repo repository.Repository, user *model.User, status string) *model.Secret {
+	t.Helper()
+
+	secret, err := repo.Secret().Create(&model.Secret{
+		User:      user.ID,
+		Parts:     2,
+		Threshold: 2,
+		Status:    status,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return secret
+}
+
+func createShare(t *testing.T, repo repository.Repository, secret *model.Secret, user *model.User, key byte) *model.Share {
+	t.Helper()
+
+	share, err := repo.Share().Create(&model.Share{
+		Secret: secret.ID,
+		User:   user.ID,
+		Key:    key,
+		Share:  []byte{key},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return share
+}
+
+func ids[T any](records []T, id func(T) string) []string {
+	result := []string{}
+	for _, r := range records {
+		result = append(result, id(r))
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+func secretIDs(secrets []model.Secret) []string {
+	return ids(secrets, func(s model.Secret) string { return s.ID })
+}
+
+func shareIDs(shares []model.Share) []string {
+	return ids(shares, func(s model.Share) string { return s.ID })
+}
+
+func equal(a, b []string) bool {
+	sort.Strings(b)
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+func testUsers(t *testing.T, repo repository.Repository) {
+	alice := upsertUser(t, repo, "alice")
+	if !strings.HasPrefix(alice.ID, "users:") {
+		t.Fatalf("id = %q, want a users: id", alice.ID)
+	}
+	if !alice.HasKey("key-alice") || len(alice.Keys) != 1 {
+		t.Fatalf("keys = %v and verified = %v, want the registered key", alice.Keys, alice.Verified)
+	}
+
+	// Upserting a known key returns its user
+	again, err := repo.User().Upsert(&model.User{Username: "alice", PublicKey: "key-alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != alice.ID {
+		t.Fatalf("upserted %s, want %s", again.ID, alice.ID)
+	}
+
+	// Another key can't register a taken username
+	if _, err := repo.User().Upsert(&model.User{Username: "alice", PublicKey: "key-other"}); !errors.Is(err, repository.ErrUsernameTaken) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrUsernameTaken)
+	}
+
+	got, err := repo.User().Get(alice.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Username != "alice" || got.Status != "active" {
+		t.Fatalf("got %+v", got)
+	}
+	if _, err := repo.User().Get(repository.NewID("users")); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	// Keys verified by an update identify the user
+	got.Verify("key-alice-laptop")
+	got.Status = "disabled"
+	if err := repo.User().Update(got); err != nil {
+		t.Fatal(err)
+	}
+	owner, err := repo.User().ForKey("key-alice-laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner.ID != alice.ID || owner.Status != "disabled" {
+		t.Fatalf("got %+v", owner)
+	}
+	if _, err := repo.User().ForKey("key-nobody"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	if err := repo.User().Update(&model.User{ID: repository.NewID("users")}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	users, err := repo.User().List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 {
+		t.Fatalf("listed %d users, want 1", len(users))
+	}
+}
+
+func testSecrets(t *testing.T, repo repository.Repository) {
+	bob := upsertUser(t, repo, "bob")
+	carol := upsertUser(t, repo, "carol")
+
+	secret := createSecret(t, repo, bob, "signing")
+	if !strings.HasPrefix(secret.ID, "secrets:") {
+		t.Fatalf("id = %q, want a secrets: id", secret.ID)
+	}
+
+	// Secrets are looked up by their ID without its table
+	short := strings.TrimPrefix(secret.ID, "secrets:")
+	got, err := repo.Secret().Get(short)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != secret.ID || got.User != bob.ID || got.Status != "signing" {
+		t.Fatalf("got %+v", got)
+	}
+	if _, err := repo.Secret().Get(secret.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	got.Status = "ready"
+	got.Label = "relabeled"
+	if err := repo.Secret().Update(got); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = repo.Secret().Get(short); err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "ready" || got.Label != "relabeled" {
+		t.Fatalf("got %+v", got)
+	}
+	if err := repo.Secret().Update(&model.Secret{ID: repository.NewID("secrets")}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrNotFound)
+	}
+
+	other := createSecret(t, repo, carol, "signing")
+
+	ready, err := repo.Secret().WithStatus("ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(secretIDs(ready), []string{secret.ID}) {
+		t.Fatalf("ready = %v, want %v", secretIDs(ready), secret.ID)
+	}
+
+	mine, err := repo.Secret().Mine(bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(secretIDs(mine), []string{secret.ID}) {
+		t.Fatalf("mine = %v, want %v", secretIDs(mine), secret.ID)
+	}
+
+	// Users hold the secrets they have current shares of, once each
+	createShare(t, repo, secret, carol, 1)
+	createShare(t, repo, secret, carol, 2)
+	revoked := createShare(t, repo, other, bob, 1)
+	revoked.Revoked = true
+	if err := repo.Share().Update(revoked); err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := repo.Secret().Held(carol.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(secretIDs(held), []string{secret.ID}) {
+		t.Fatalf("held = %v, want %v", secretIDs(held), secret.ID)
+	}
+	if held, err = repo.Secret().Held(bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(held) != 0 {
+		t.Fatalf("held = %v, want none", secretIDs(held))
+	}
+
+	all, err := repo.Secret().All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(secretIDs(all), []string{secret.ID, other.ID}) {
+		t.Fatalf("all = %v, want %v and %v", secretIDs(all), secret.ID, other.ID)
+	}
+}
+
+func testShares(t *testing.T, repo repository.Repository) {
+	dave := upsertUser(t, repo, "dave")
+	erin := upsertUser(t, repo, "erin")
+	secret := createSecret(t, repo, dave, "ready")
+
+	first := createShare(t, repo, secret, dave, 1)
+	second := createShare(t, repo, secret, erin, 2)
+	third := createShare(t, repo, secret, erin, 3)
+
+	shares, err := repo.Share().ForSecret(secret.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(shareIDs(shares), []string{first.ID, second.ID, third.ID}) {
+		t.Fatalf("shares = %v", shareIDs(shares))
+	}
+
+	// Revoked shares are left out
+	third.Revoked = true
+	if err := repo.Share().Update(third); err != nil {
+		t.Fatal(err)
+	}
+	if shares, err = repo.Share().ForSecret(secret.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(shareIDs(shares), []string{first.ID, second.ID}) {
+		t.Fatalf("shares = %v", shareIDs(shares))
+	}
+
+	mine, err := repo.Share().MineForSecret(secret.ID, erin.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(shareIDs(mine), []string{second.ID}) {
+		t.Fatalf("mine = %v, want %v", shareIDs(mine), second.ID)
+	}
+	if mine[0].Key != 2 || string(mine[0].Share) != "\x02" {
+		t.Fatalf("got %+v", mine[0])
+	}
+
+	if err := repo.Share().Update(&model.Share{ID: repository.NewID("shares")}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrNotFound)
+	}
+}
+
+func testDelete(t *testing.T, repo repository.Repository) {
+	frank := upsertUser(t, repo, "frank")
+	grace := upsertUser(t, repo, "grace")
+	secret := createSecret(t, repo, frank, "ready")
+	kept := createSecret(t, repo, frank, "ready")
+
+	createShare(t, repo, secret, frank, 1)
+	createShare(t, repo, secret, grace, 2)
+	share := createShare(t, repo, kept, grace, 1)
+
+	if err := repo.Secret().Delete(secret.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// The secret goes along with its shares and their relations
+	if _, err := repo.Secret().Get(strings.TrimPrefix(secret.ID, "secrets:")); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrNotFound)
+	}
+	shares, err := repo.Share().ForSecret(secret.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 0 {
+		t.Fatalf("shares = %v, want none", shareIDs(shares))
+	}
+	if shares, err = repo.Share().MineForSecret(secret.ID, grace.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 0 {
+		t.Fatalf("mine = %v, want none", shareIDs(shares))
+	}
+
+	mine, err := repo.Secret().Mine(frank.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(secretIDs(mine), []string{kept.ID}) {
+		t.Fatalf("mine = %v, want %v", secretIDs(mine), kept.ID)
+	}
+	held, err := repo.Secret().Held(grace.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equal(secretIDs(held), []string{kept.ID}) {
+		t.Fatalf("held = %v, want %v", secretIDs(held), kept.ID)
+	}
+	if held, err = repo.Secret().Held(frank.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(held) != 0 {
+		t.Fatalf("held = %v, want none", secretIDs(held))
+	}
+
+	// Other secrets keep their shares
+	if shares, err = repo.Share().ForSecret(kept.ID); err != nil {
+		t.Fatal(err)
+	}
+	if !equal(shareIDs(shares), []string{share.ID}) {
+		t.Fatalf("shares = %v, want %v", shareIDs(shares), share.ID)
+	}
+
+	if err := repo.Secret().Delete(secret.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("err = %v, want %v", err, repository.ErrNotFound)
+	}
+}
@@ -0,0 +1,56 @@
+package surreal
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/defval/di"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+type SurrealAuditRepository struct {
+	di.Inject
+	DB *surrealdb.DB
+}
+
+func (r SurrealAuditRepository) Append(event *model.Event) (*model.Event, error) {
+	data, err := r.DB.Create("events", event)
+	if err != nil {
+		return nil, err
+	}
+
+	ne := make([]model.Event, 1)
+	if err := surrealdb.Unmarshal(data, &ne); err != nil {
+		return nil, err
+	}
+
+	return &ne[0], nil
+}
+
+func (r SurrealAuditRepository) All() ([]model.Event, error) {
+	data, err := r.DB.Query("SELECT * FROM events ORDER BY created_at", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []surrealdb.RawQuery[[]model.Event]{}
+	if err := surrealdb.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result[0].Result, nil
+}
+
+func (r SurrealAuditRepository) ForSecret(secretID string) ([]model.Event, error) {
+	data, err := r.DB.Query("SELECT * FROM events WHERE secret = $secret ORDER BY created_at", map[string]interface{}{
+		"secret": secretID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []surrealdb.RawQuery[[]model.Event]{}
+	if err := surrealdb.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result[0].Result, nil
+}
@@ -0,0 +1,71 @@
+package surreal
+
+import (
+	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/defval/di"
+	"github.com/surrealdb/surrealdb.go"
+)
+
+type SurrealCeremonyRepository struct {
+	di.Inject
+	DB *surrealdb.DB
+}
+
+func (r SurrealCeremonyRepository) All() ([]model.Ceremony, error) {
+	data, err := r.DB.Select("ceremonies")
+	if err != nil {
+		return nil, err
+	}
+
+	ceremonies := []model.Ceremony{}
+	if err := surrealdb.Unmarshal(data, &ceremonies); err != nil {
+		return nil, err
+	}
+
+	return ceremonies, nil
+}
+
+func (r SurrealCeremonyRepository) ForSecret(secretID string) (*model.Ceremony, error) {
+	data, err := r.DB.Query("SELECT * FROM ceremonies WHERE secret = $secret", map[string]interface{}{
+		"secret": secretID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []surrealdb.RawQuery[[]model.Ceremony]{}
+	if err := surrealdb.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result[0].Result) == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return &result[0].Result[0], nil
+}
+
+func (r SurrealCeremonyRepository) Create(ceremony *model.Ceremony) (*model.Ceremony, error) {
+	data, err := r.DB.Create("ceremonies", ceremony)
+	if err != nil {
+		return nil, err
+	}
+
+	nc := make([]model.Ceremony, 1)
+	if err := surrealdb.Unmarshal(data, &nc); err != nil {
+		return nil, err
+	}
+
+	return &nc[0], nil
+}
+
+func (r SurrealCeremonyRepository) Update(ceremony *model.Ceremony) error {
+	_, err := r.DB.Update(ceremony.ID, ceremony)
+	return err
+}
+
+func (r SurrealCeremonyRepository) Delete(id string) error {
+	_, err := r.DB.Delete(id)
+	return err
+}
@@ -22,3 +22,11 @@ func (r SurrealRepository) Share() repository.ShareRepository {
 func (r SurrealRepository) Secret() repository.SecretRepository {
 	return lib.MustAutoResolve[SurrealSecretRepository]()
 }
+
+func (r SurrealRepository) Ceremony() repository.CeremonyRepository {
+	return lib.MustAutoResolve[SurrealCeremonyRepository]()
+}
+
+func (r SurrealRepository) Audit() repository.AuditRepository {
+	return lib.MustAutoResolve[SurrealAuditRepository]()
+}
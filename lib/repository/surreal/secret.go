@@ -11,6 +11,21 @@ type SurrealSecretRepository struct {
 	DB *surrealdb.DB
 }
 
+// All implements SecretRepository.
+func (r SurrealSecretRepository) All() ([]model.Secret, error) {
+	data, err := r.DB.Select("secrets")
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := []model.Secret{}
+	if err := surrealdb.Unmarshal(data, &secrets); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
 // Get implements SecretRepository.
 func (r SurrealSecretRepository) Get(id string) (*model.Secret, error) {
 	data, err := r.DB.Select("secrets:" + id)
@@ -42,6 +57,38 @@ func (r SurrealSecretRepository) Mine(userID string) ([]model.Secret, error) {
 	return result[0].Result, nil
 }
 
+func (r SurrealSecretRepository) Held(userID string) ([]model.Secret, error) {
+	data, err := r.DB.Query("SELECT * FROM secrets WHERE id INSIDE (SELECT VALUE secret FROM shares WHERE user = $user AND revoked != true)", map[string]interface{}{
+		"user": userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []surrealdb.RawQuery[[]model.Secret]{}
+	if err := surrealdb.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result[0].Result, nil
+}
+
+func (r SurrealSecretRepository) WithStatus(status string) ([]model.Secret, error) {
+	data, err := r.DB.Query("SELECT * FROM secrets WHERE status = $status", map[string]interface{}{
+		"status": status,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []surrealdb.RawQuery[[]model.Secret]{}
+	if err := surrealdb.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result[0].Result, nil
+}
+
 // Create implements SecretRepository.
 func (r SurrealSecretRepository) Create(secret *model.Secret) (*model.Secret, error) {
 	data, err := r.DB.Create("secrets", secret)
@@ -70,3 +117,19 @@ func (r SurrealSecretRepository) Update(secret *model.Secret) error {
 	_, err := r.DB.Update(secret.ID, secret)
 	return err
 }
+
+// Delete implements SecretRepository.
+func (r SurrealSecretRepository) Delete(id string) error {
+	_, err := r.DB.Query(`
+		BEGIN TRANSACTION;
+		DELETE signed WHERE out.secret = $secret;
+		DELETE split_into WHERE in = $secret;
+		DELETE created WHERE out = $secret;
+		DELETE shares WHERE secret = $secret;
+		DELETE $secret;
+		COMMIT TRANSACTION;
+	`, map[string]interface{}{
+		"secret": id,
+	})
+	return err
+}
@@ -11,8 +11,24 @@ type SurrealShareRepository struct {
 	DB *surrealdb.DB
 }
 
+func (r SurrealShareRepository) ForSecret(secretID string) ([]model.Share, error) {
+	data, err := r.DB.Query("SELECT * FROM shares WHERE secret = $id AND revoked != true", map[string]interface{}{
+		"id": secretID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := []surrealdb.RawQuery[[]model.Share]{}
+	if err := surrealdb.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result[0].Result, nil
+}
+
 func (r SurrealShareRepository) MineForSecret(secretID string, userID string) ([]model.Share, error) {
-	data, err := r.DB.Query("SELECT * FROM shares WHERE secret = $id AND user = $user", map[string]interface{}{
+	data, err := r.DB.Query("SELECT * FROM shares WHERE secret = $id AND user = $user AND revoked != true", map[string]interface{}{
 		"id":   secretID,
 		"user": userID,
 	})
@@ -57,3 +73,8 @@ func (r SurrealShareRepository) Create(share *model.Share) (*model.Share, error)
 
 	return &ns[0], nil
 }
+
+func (r SurrealShareRepository) Update(share *model.Share) error {
+	_, err := r.DB.Update(share.ID, share)
+	return err
+}
@@ -1,7 +1,11 @@
 package surreal
 
 import (
+	"errors"
+	"time"
+
 	"github.com/adamgoose/ssss/lib/model"
+	"github.com/adamgoose/ssss/lib/repository"
 	"github.com/defval/di"
 	"github.com/surrealdb/surrealdb.go"
 )
@@ -11,23 +15,108 @@ type SurrealUserRepository struct {
 	DB *surrealdb.DB
 }
 
+func (r SurrealUserRepository) Get(id string) (*model.User, error) {
+	data, err := r.DB.Select(id)
+	if err != nil {
+		return nil, err
+	}
+
+	user := model.User{}
+	if err := surrealdb.Unmarshal(data, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r SurrealUserRepository) List() ([]model.User, error) {
+	data, err := r.DB.Select("users")
+	if err != nil {
+		return nil, err
+	}
+
+	users := []model.User{}
+	if err := surrealdb.Unmarshal(data, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r SurrealUserRepository) ForKey(publicKey string) (*model.User, error) {
+	users, err := r.query("SELECT * FROM users WHERE $public_key INSIDE verified OR (public_key = $public_key AND (verified = NONE OR verified = [])) LIMIT 1", map[string]interface{}{
+		"public_key": publicKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) == 0 {
+		return nil, repository.ErrNotFound
+	}
+
+	return &users[0], nil
+}
+
 func (r SurrealUserRepository) Upsert(user *model.User) (*model.User, error) {
-	data, err := r.DB.Query(`
-		INSERT INTO users (id, username, public_key, first_seen, last_seen)
-		VALUES ([$username, $public_key], $username, $public_key, time::now(), time::now())
+	existing, err := r.ForKey(user.PublicKey)
+	switch {
+	case err == nil:
+		existing.LastSeen = time.Now()
+		_, err := r.DB.Change(existing.ID, map[string]interface{}{
+			"last_seen": existing.LastSeen,
+		})
+		return existing, err
+	case !errors.Is(err, repository.ErrNotFound):
+		return nil, err
+	}
+
+	taken, err := r.query("SELECT * FROM users WHERE username = $username LIMIT 1", map[string]interface{}{
+		"username": user.Username,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(taken) > 0 {
+		return nil, repository.ErrUsernameTaken
+	}
+
+	users, err := r.query(`
+		INSERT INTO users (id, username, public_key, keys, verified, status, first_seen, last_seen)
+		VALUES ([$username, $public_key], $username, $public_key, [$public_key], [$public_key], $status, time::now(), time::now())
 		ON DUPLICATE KEY UPDATE last_seen = time::now()
   `, map[string]interface{}{
 		"username":   user.Username,
 		"public_key": user.PublicKey,
+		"status":     user.Status,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	return &users[0], nil
+}
+
+func (r SurrealUserRepository) Update(user *model.User) error {
+	_, err := r.DB.Change(user.ID, map[string]interface{}{
+		"public_key": user.PublicKey,
+		"keys":       user.Keys,
+		"verified":   user.Verified,
+		"status":     user.Status,
+	})
+	return err
+}
+
+func (r SurrealUserRepository) query(sql string, vars map[string]interface{}) ([]model.User, error) {
+	data, err := r.DB.Query(sql, vars)
+	if err != nil {
+		return nil, err
+	}
+
 	result := []surrealdb.RawQuery[[]model.User]{}
 	if err := surrealdb.Unmarshal(data, &result); err != nil {
 		return nil, err
 	}
 
-	return &result[0].Result[0], nil
+	return result[0].Result, nil
 }
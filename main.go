@@ -1,47 +1,90 @@
 package main
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/adamgoose/ssss/cmd"
 	"github.com/adamgoose/ssss/lib"
 	"github.com/adamgoose/ssss/lib/repository"
+	"github.com/adamgoose/ssss/lib/repository/bolt"
+	"github.com/adamgoose/ssss/lib/repository/memory"
 	"github.com/adamgoose/ssss/lib/repository/surreal"
 	_ "github.com/corvus-ch/shamir"
 	"github.com/defval/di"
+	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/surrealdb/surrealdb.go"
+	"go.etcd.io/bbolt"
 )
 
 func main() {
-	if err := lib.Apply(
-		di.Provide(func() (*surrealdb.DB, error) {
-			db, err := surrealdb.New(viper.GetString("surrealdb_address"))
+	rootCmd := &cobra.Command{
+		Use:           "ssss",
+		Short:         "Serves Shamir's Secret Sharing Scheme over SSH.",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(c *cobra.Command, args []string) error {
+			storage, err := storageDriver(viper.GetString("storage_driver"))
 			if err != nil {
-				return nil, err
+				return err
 			}
 
-			if _, err = db.Signin(map[string]interface{}{
-				"user": viper.GetString("surrealdb_user"),
-				"pass": viper.GetString("surrealdb_pass"),
-			}); err != nil {
-				return nil, err
+			if err := lib.Apply(storage...); err != nil {
+				return err
 			}
 
-			if _, err = db.Use(viper.GetString("surrealdb_ns"), viper.GetString("surrealdb_db")); err != nil {
-				return nil, err
-			}
+			return lib.Invoke(cmd.RunE)
+		},
+	}
+	rootCmd.AddCommand(cmd.NewUnwrapCmd())
 
-			return db, nil
-		}),
-		di.ProvideValue(surreal.SurrealRepository{}, di.As(new(repository.Repository))),
-	); err != nil {
+	if err := rootCmd.Execute(); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	if err := lib.Invoke(cmd.RunE); err != nil {
-		log.Fatal(err)
+func storageDriver(driver string) ([]di.Option, error) {
+	switch driver {
+	case "surreal":
+		return []di.Option{
+			di.Provide(func() (*surrealdb.DB, error) {
+				db, err := surrealdb.New(viper.GetString("surrealdb_address"))
+				if err != nil {
+					return nil, err
+				}
+
+				if _, err = db.Signin(map[string]interface{}{
+					"user": viper.GetString("surrealdb_user"),
+					"pass": viper.GetString("surrealdb_pass"),
+				}); err != nil {
+					return nil, err
+				}
+
+				if _, err = db.Use(viper.GetString("surrealdb_ns"), viper.GetString("surrealdb_db")); err != nil {
+					return nil, err
+				}
+
+				return db, nil
+			}),
+			di.ProvideValue(surreal.SurrealRepository{}, di.As(new(repository.Repository))),
+		}, nil
+	case "bolt":
+		return []di.Option{
+			di.Provide(func() (*bbolt.DB, error) {
+				return bolt.Open(viper.GetString("bolt_path"))
+			}),
+			di.ProvideValue(bolt.BoltRepository{}, di.As(new(repository.Repository))),
+		}, nil
+	case "memory":
+		return []di.Option{
+			di.Provide(memory.NewStore),
+			di.ProvideValue(memory.MemoryRepository{}, di.As(new(repository.Repository))),
+		}, nil
 	}
+
+	return nil, fmt.Errorf("unknown storage driver %q", driver)
 }
 
 func init() {
@@ -51,9 +94,21 @@ func init() {
 	viper.SetDefault("host", "127.0.0.1")
 	viper.SetDefault("port", "23234")
 	viper.SetDefault("host_key_path", ".ssh/id_ed25519")
+	viper.SetDefault("split_timeout", "24h")
+	viper.SetDefault("combine_timeout", "1h")
+	viper.SetDefault("reshare_timeout", "24h")
+	viper.SetDefault("unsign_attempts", 5)
+	viper.SetDefault("unsign_lockout", "15m")
+	viper.SetDefault("key_claim_timeout", "15m")
+	viper.SetDefault("storage_driver", "surreal")
 	viper.SetDefault("surrealdb_address", "ws://127.0.0.1:4222/rpc")
 	viper.SetDefault("surrealdb_user", "root")
 	viper.SetDefault("surrealdb_pass", "root")
 	viper.SetDefault("surrealdb_ns", "ssss")
 	viper.SetDefault("surrealdb_db", "ssss")
+	viper.SetDefault("bolt_path", "ssss.db")
+	viper.SetDefault("inactive_after", "720h")
+	viper.SetDefault("registration", "open")
+	viper.SetDefault("authorized_keys_path", ".ssh/authorized_keys")
+	viper.SetDefault("authorized_keys_dir", ".ssh/authorized_keys.d")
 }
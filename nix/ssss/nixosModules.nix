@@ -34,6 +34,23 @@
           '';
         };
 
+        storageDriver = l.mkOption {
+          default = "surreal";
+          type = l.types.enum [ "surreal" "bolt" "memory" ];
+          description = l.mdDoc ''
+            The storage backend to use. The memory backend loses all secrets
+            when the service stops.
+          '';
+        };
+
+        boltPath = l.mkOption {
+          default = "/var/lib/ssss/ssss.db";
+          type = l.types.str;
+          description = l.mdDoc ''
+            The location of the database file used by the bolt storage driver.
+          '';
+        };
+
         surrealdb = {
 
           address = l.mkOption {
@@ -95,6 +112,8 @@
             SSSS_HOST = cfg.host;
             SSSS_PORT = "${toString cfg.port}";
             SSSS_HOST_KEY_PATH = cfg.hostKeyPath;
+            SSSS_STORAGE_DRIVER = cfg.storageDriver;
+            SSSS_BOLT_PATH = cfg.boltPath;
             SSSS_SURREALDB_ADDRESS = cfg.surrealdb.address;
             SSSS_SURREALDB_USER = cfg.surrealdb.user;
             SSSS_SURREALDB_PASS = cfg.surrealdb.pass;